@@ -3,14 +3,102 @@ package config
  
 import (
     "database/sql"
+    "time"
 )
+
+// @Desc: How often the scheduler loop checks for notifications that are due to be sent.
+const SchedulerInterval = 30 * time.Second
+
+// @Desc: Scheduled notifications whose delivery fails are retried on the following scheduler ticks, and marked failed after MaxDeliveryAttempts.
+const MaxDeliveryAttempts = 3
+
+// @Desc: How {{.Date}} is written when a notification template is rendered.
+const TemplateDateLayout = "2 Jan 2006"
+
+// @Desc: Daily digests are built once a day at DigestDailyHour in DigestTimezone, which is also the timezone digests show send times in.
+const DigestTimezone = "Asia/Singapore"
+const DigestDailyHour = 18
+
+// @Desc: Low priority notifications are never pushed straight away: they go into the student's digest, or into one of LowPriorityDigest frequency for students without digests.
+const LowPriorityDigest = "daily"
+
+// @Desc: Recipients who have not acknowledged a notification requiring it are reminded every AckReminderInterval, at most AckMaxReminders times.
+const AckReminderInterval = 24 * time.Hour
+const AckMaxReminders = 3
+
+// @Desc: How long after sending a teacher can still edit or recall a notification.
+const RecallWindow = 15 * time.Minute
+
+// @Desc: How long expired notifications are kept, for teachers' acknowledgement reports & replies, before the scheduler purges them.
+const ExpiredNotificationRetention = 7 * 24 * time.Hour
+
+// @Desc: Language notifications are written in unless the teacher says otherwise, and the variant students get when none is in their preferred language.
+const FallbackLanguage = "en"
+
+// @Desc: Default quota of every teacher, which administrators can override per teacher.
+const RateLimitNotificationsPerHour = 30
+const RateLimitNotificationsPerDay = 200
+const RateLimitRecipientsPerDay = 5000
+
+// @Desc: Longest reply, in characters, a student can post to a notification.
+const MaxReplyLength = 1000
+
+// @Desc: Base URL this service is reachable at, used to build links sent to students e.g. unsubscribe links.
+const PublicBaseURL = "http://localhost:8080"
+
+// @Desc: Secret used to sign unsubscribe tokens. *Change accordingly
+const UnsubscribeSecret = "change-me-unsubscribe-secret"
+
+// @Desc: Token school administrators send in the X-Admin-Token header to authorise admin-only actions. *Change accordingly
+const AdminToken = "change-me-admin-token"
+
+// @Desc: Secret used to sign the tokens classroom displays connect to the live gateway with. *Change accordingly
+const GatewaySecret = "change-me-gateway-secret"
+
+// @Desc: Directory attachments are stored in, at most MaxAttachments of up to MaxAttachmentSize bytes each per notification.
+const BlobStorageDir = "./attachments"
+const MaxAttachments = 5
+const MaxAttachmentSize int64 = 10 << 20
+
+// @Desc: Index notifications are searched through, "fulltext" for the FULLTEXT index of the database, or "memory" for databases without one.
+const SearchBackend = "fulltext"
+
+// @Desc: Secret used to sign attachment download URLs, which stay valid for AttachmentURLTTL. *Change accordingly
+const AttachmentURLSecret = "change-me-attachment-secret"
+const AttachmentURLTTL = 7 * 24 * time.Hour
+
+// @Desc: SMTP server (host:port) and sender used by the email channel, which is disabled while the address is empty. *Change accordingly
+const SMTPAddress = ""
+const SMTPFrom = "notifications@school.edu.sg"
+
+// @Desc: HTTP gateway the SMS channel sends through, which is disabled while the URL is empty, and the sender name texts come from. *Change accordingly
+const SMSGatewayURL = ""
+const SMSGatewayToken = "change-me-sms-token"
+const SMSSender = "SCHOOL"
+
+// @Desc: Longer texts are split into at most SMSMaxSegments SMS, and cut short beyond that.
+const SMSMaxSegments = 4
+
+// @Desc: A rule of the built-in content filter, a regular expression that either blocks the notification, redacts what it matches or only warns the teacher.
+type ModerationRule struct {
+    Name string
+    Pattern string
+    Action string
+}
+
+// @Desc: Rules of the built-in content filter, checked against every notification before it is sent. *Change accordingly
+var ModerationRules = []ModerationRule{
+    {Name: "nric", Pattern: `(?i)\b[STFGM]\d{7}[A-Z]\b`, Action: "block"},
+    {Name: "profanity", Pattern: `(?i)\b(damn|crap|stupid)\b`, Action: "redact"},
+    {Name: "phone-number", Pattern: `\b[689]\d{3} ?\d{4}\b`, Action: "warn"},
+}
  
 // @Desc: Open connection to mySQL database
 func Connect() *sql.DB {
   
   
 	// *Change accordingly:
-    db, err := sql.Open("mysql", "username:password@tcp(127.0.0.1:3306)/sys")
+    db, err := sql.Open("mysql", "username:password@tcp(127.0.0.1:3306)/sys?parseTime=true")
 
     // if there is an error opening the connection, handle it
     if err != nil {
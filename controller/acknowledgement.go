@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const streamEventAcknowledgement = "acknowledgement"
+
+/*///////////////////////////////////////////////////////////////
+                      Acknowledgement Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// AcknowledgeNotification: Confirm a notification that requires acknowledgement, which also marks it as read
+// URL : /students/{student}/inbox/{id}/acknowledge
+// Parameters: student, id
+// Method: POST
+// Output: No content if successful, else error message.
+func AcknowledgeNotification(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	notification, err := getNotification(db, id)
+	if err != nil && err != sql.ErrNoRows {
+		ErrorResponse("Failed to acknowledge notification.", w, http.StatusNotFound)
+		return
+	}
+
+	var received int
+	if err == nil {
+		err = db.QueryRow("SELECT COUNT(*) FROM NotificationRecipient WHERE notification_id = ? AND student = ?", id, student).Scan(&received)
+		if err != nil {
+			ErrorResponse("Failed to acknowledge notification.", w, http.StatusNotFound)
+			return
+		}
+	}
+	if received == 0 {
+		ErrorResponse("Notification not found in inbox.", w, http.StatusNotFound)
+		return
+	}
+	if !notification.RequiresAck {
+		ErrorResponse("Notification does not require acknowledgement.", w, http.StatusConflict)
+		return
+	}
+	if notification.Status == notificationStatusRecalled {
+		ErrorResponse("Notification has been recalled.", w, http.StatusConflict)
+		return
+	}
+	if notificationExpired(notification, time.Now()) {
+		ErrorResponse("Notification has expired.", w, http.StatusConflict)
+		return
+	}
+
+	// The notification is checked again as part of the update, as it may be recalled in between
+	now := time.Now().UTC()
+	result, err := db.Exec(`UPDATE NotificationRecipient AS Recipient JOIN NotificationMessage AS Message ON Message.id = Recipient.notification_id
+	SET Recipient.acknowledged_at = ?, Recipient.read_at = COALESCE(Recipient.read_at, ?)
+	WHERE Recipient.notification_id = ? AND Recipient.student = ? AND Recipient.acknowledged_at IS NULL
+	AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?)`, now, now, id, student, notificationStatusSent, now)
+	if err != nil {
+		ErrorResponse("Failed to acknowledge notification.", w, http.StatusNotFound)
+		return
+	}
+
+	// Acknowledging again is a no-op, only the first acknowledgement is reported to the teacher
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		notificationHub.Publish(teacherTopic(notification.Teacher), streamEventAcknowledgement, model.AcknowledgementStatus{
+			NotificationId: id,
+			Student:        student,
+			AcknowledgedAt: &now,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcknowledgementReport: Report which recipients of a teacher's notification have and have not acknowledged it
+// URL : /notifications/{id}/acknowledgements
+// Parameters: id, teacher
+// Method: GET
+// Output: JSON Encoded Object of acknowledged & pending recipients, else error message.
+func AcknowledgementReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+	teacher := r.URL.Query().Get("teacher")
+	if len(teacher) == 0 {
+		ErrorResponse("No teacher specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	notification, err := getNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && notification.Teacher != teacher) {
+		ErrorResponse("Notification not found.", w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return
+	}
+	if !notification.RequiresAck {
+		ErrorResponse("Notification does not require acknowledgement.", w, http.StatusConflict)
+		return
+	}
+
+	report, err := getAcknowledgementReport(db, notification)
+	if err != nil {
+		ErrorResponse("Failed to retrieve acknowledgements.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(report)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Acknowledgement Reminders
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Scheduler] Re-send notifications requiring acknowledgement to recipients who have not acknowledged them, every AckReminderInterval up to AckMaxReminders times.
+func sendAcknowledgementReminders(db *sql.DB, now time.Time) error {
+	due := now.Add(-config.AckReminderInterval).UTC()
+	rows, err := db.Query(`SELECT Message.id, Message.teacher, COALESCE(Recipient.message, Message.message), Message.format, Recipient.student, Recipient.reminders_sent
+	FROM NotificationRecipient AS Recipient JOIN NotificationMessage AS Message ON Message.id = Recipient.notification_id
+	WHERE Message.requires_ack = 1 AND Message.status = ? AND Recipient.acknowledged_at IS NULL AND Recipient.reminders_sent < ?
+	AND COALESCE(Recipient.last_reminded_at, Message.sent_at) <= ? AND (Message.expires_at IS NULL OR Message.expires_at > ?)`,
+		notificationStatusSent, config.AckMaxReminders, due, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	type reminder struct {
+		message       channelMessage
+		remindersSent int
+	}
+	var reminders []reminder
+	for rows.Next() {
+		var r reminder
+		var format string
+		if err := rows.Scan(&r.message.NotificationId, &r.message.Teacher, &r.message.Text, &format, &r.message.Student, &r.remindersSent); err != nil {
+			rows.Close()
+			return err
+		}
+		r.message.Text, r.message.HTML = renderNotificationBody(r.message.Text, format)
+		reminders = append(reminders, r)
+	}
+	rows.Close()
+
+	for _, r := range reminders {
+		preferences, err := getStudentPreferences(db, r.message.Student)
+		if err != nil {
+			return err
+		}
+		// Picked up again on a later tick once quiet hours are over
+		if inQuietHours(preferences, now) {
+			continue
+		}
+
+		// Claim the reminder first so that another scheduler cannot send it twice
+		result, err := db.Exec(`UPDATE NotificationRecipient SET reminders_sent = reminders_sent + 1, last_reminded_at = ?
+		WHERE notification_id = ? AND student = ? AND reminders_sent = ? AND acknowledged_at IS NULL`,
+			now.UTC(), r.message.NotificationId, r.message.Student, r.remindersSent)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+
+		r.message.Subject = "Reminder: please acknowledge a notification from " + r.message.Teacher
+		r.message.Text = "Please acknowledge this notification:\n\n" + r.message.Text
+		pushNotification(db, r.message, preferences)
+		log.Printf("Sent acknowledgement reminder %d for notification %d to %s", r.remindersSent+1, r.message.NotificationId, r.message.Student)
+	}
+	return nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Acknowledgement] Every recipient of the notification, split by whether they have acknowledged it.
+func getAcknowledgementReport(db *sql.DB, notification model.ScheduledNotification) (model.AcknowledgementReport, error) {
+	report := model.AcknowledgementReport{
+		NotificationId: notification.Id,
+		Teacher:        notification.Teacher,
+		Notification:   notification.Notification,
+		Acknowledged:   make([]model.AcknowledgementStatus, 0),
+		Pending:        make([]model.AcknowledgementStatus, 0),
+	}
+
+	rows, err := db.Query(`SELECT student, acknowledged_at, reminders_sent, last_reminded_at FROM NotificationRecipient
+	WHERE notification_id = ? ORDER BY student`, notification.Id)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		status := model.AcknowledgementStatus{NotificationId: notification.Id}
+		var acknowledgedAt, lastRemindedAt sql.NullTime
+		if err := rows.Scan(&status.Student, &acknowledgedAt, &status.RemindersSent, &lastRemindedAt); err != nil {
+			return report, err
+		}
+		if lastRemindedAt.Valid {
+			status.LastRemindedAt = &lastRemindedAt.Time
+		}
+		if acknowledgedAt.Valid {
+			status.AcknowledgedAt = &acknowledgedAt.Time
+			report.Acknowledged = append(report.Acknowledged, status)
+		} else {
+			report.Pending = append(report.Pending, status)
+		}
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Acknowledgements
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Acknowledging a notification on behalf of an invalid student email, which should fail with HTTP code 400.
+func TestAcknowledgeNotificationInvalidStudent(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/students/s1/inbox/1/acknowledge", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1", "id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(AcknowledgeNotification)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid student email format."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestAcknowledgeNotificationInvalidStudent")
+}
+
+// @Desc: [FAIL] Acknowledging a notification with a non-numeric id, which should fail with HTTP code 400.
+func TestAcknowledgeNotificationInvalidId(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/students/s1@gmail.com/inbox/abc/acknowledge", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com", "id": "abc"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(AcknowledgeNotification)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid notification id."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestAcknowledgeNotificationInvalidId")
+}
+
+// @Desc: [FAIL] Requesting an acknowledgement report without specifying the teacher, which should fail with HTTP code 400.
+func TestAcknowledgementReportNoTeacher(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/notifications/1/acknowledgements", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(AcknowledgementReport)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"No teacher specified."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestAcknowledgementReportNoTeacher")
+}
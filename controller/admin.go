@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+)
+
+// Header school administrators authorise admin-only actions with
+const adminTokenHeader = "X-Admin-Token"
+
+// @Desc: [Admin] Whether the request carries the admin token configured inside `config.go`.
+func isAdminRequest(r *http.Request) bool {
+	token := r.Header.Get(adminTokenHeader)
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) == 1
+}
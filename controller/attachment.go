@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Form fields of a multipart notification request
+const (
+	multipartRequestField    = "request"
+	multipartAttachmentField = "attachments"
+)
+
+// An attachment type is accepted by its extension, as long as its content sniffs as expected
+type attachmentType struct {
+	ContentType string
+	Sniffed     string
+}
+
+var allowedAttachmentTypes = map[string]attachmentType{
+	".pdf":  {"application/pdf", "application/pdf"},
+	".png":  {"image/png", "image/png"},
+	".jpg":  {"image/jpeg", "image/jpeg"},
+	".jpeg": {"image/jpeg", "image/jpeg"},
+	".txt":  {"text/plain; charset=utf-8", "text/plain; charset=utf-8"},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip"},
+	".xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/zip"},
+	".pptx": {"application/vnd.openxmlformats-officedocument.presentationml.presentation", "application/zip"},
+}
+
+// A validated attachment uploaded along with a notification, not stored yet
+type upload struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+	Hash        string
+}
+
+// requestError: A malformed request, answered with its own message & status code
+type requestError struct {
+	Message string
+	Status  int
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+/*///////////////////////////////////////////////////////////////
+                         Attachment Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// DownloadAttachment: Download an attachment through the signed URL given to its recipients
+// URL : /attachments/{id}
+// Parameters: id, recipient, expires, signature
+// Method: GET
+// Output: The attachment's content, else error message.
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid attachment id.", w, http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !validAttachmentSignature(id, query.Get("recipient"), expires, query.Get("signature")) {
+		ErrorResponse("Invalid attachment signature.", w, http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expires {
+		ErrorResponse("Attachment link has expired.", w, http.StatusForbidden)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	var attachment model.Attachment
+	var status string
+	var expiresAt sql.NullTime
+	err = db.QueryRow(`SELECT Attachment.id, Attachment.filename, Attachment.content_type, Attachment.size, Attachment.sha256, Message.status, Message.expires_at
+	FROM Attachment JOIN NotificationMessage AS Message ON Message.id = Attachment.notification_id WHERE Attachment.id = ?`, id).
+		Scan(&attachment.Id, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.SHA256, &status, &expiresAt)
+	if err == sql.ErrNoRows {
+		ErrorResponse("Attachment not found.", w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve attachment.", w, http.StatusNotFound)
+		return
+	}
+	// Links already handed out stop working once the notification is withdrawn
+	if status == notificationStatusRecalled {
+		ErrorResponse("Notification has been recalled.", w, http.StatusGone)
+		return
+	}
+	if status == notificationStatusExpired || (expiresAt.Valid && !expiresAt.Time.After(time.Now())) {
+		ErrorResponse("Notification has expired.", w, http.StatusGone)
+		return
+	}
+
+	content, err := currentBlobStore().Open(attachment.SHA256)
+	if err != nil {
+		ErrorResponse("Failed to retrieve attachment.", w, http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	io.Copy(w, content)
+}
+
+/*///////////////////////////////////////////////////////////////
+                         Multipart Requests
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [RetrieveForNotification] Decode a notification request, either a JSON body or a multipart form with the JSON in its "request" field
+// and up to MaxAttachments files in its "attachments" field.
+func decodeNotificationRequest(w http.ResponseWriter, r *http.Request, requestBody *model.RetrieveForNotificationBody) ([]upload, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(requestBody); err != nil {
+			return nil, &requestError{"Invalid request body format.", http.StatusBadRequest}
+		}
+		return nil, nil
+	}
+
+	// Room for every attachment at its largest along with the rest of the form
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxAttachments)*config.MaxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &requestError{"Request is too large.", http.StatusRequestEntityTooLarge}
+		}
+		return nil, &requestError{"Invalid request body format.", http.StatusBadRequest}
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	if err := json.Unmarshal([]byte(r.FormValue(multipartRequestField)), requestBody); err != nil {
+		return nil, &requestError{"Invalid request body format.", http.StatusBadRequest}
+	}
+
+	files := r.MultipartForm.File[multipartAttachmentField]
+	if len(files) > config.MaxAttachments {
+		return nil, &requestError{fmt.Sprintf("At most %d attachments are allowed.", config.MaxAttachments), http.StatusBadRequest}
+	}
+
+	uploads := make([]upload, 0, len(files))
+	for _, file := range files {
+		upload, err := readUpload(file)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// @Desc: [Attachments] Read an uploaded file, enforcing the size & type limits, and hash its content.
+func readUpload(file *multipart.FileHeader) (upload, error) {
+	filename := filepath.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+	allowed, ok := allowedAttachmentTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return upload{}, &requestError{"Unsupported attachment type: " + filename + ".", http.StatusUnsupportedMediaType}
+	}
+	if file.Size > config.MaxAttachmentSize {
+		return upload{}, &requestError{"Attachment too large: " + filename + ".", http.StatusRequestEntityTooLarge}
+	}
+
+	opened, err := file.Open()
+	if err != nil {
+		return upload{}, &requestError{"Invalid request body format.", http.StatusBadRequest}
+	}
+	defer opened.Close()
+	content, err := io.ReadAll(io.LimitReader(opened, config.MaxAttachmentSize+1))
+	if err != nil {
+		return upload{}, &requestError{"Invalid request body format.", http.StatusBadRequest}
+	}
+	if int64(len(content)) > config.MaxAttachmentSize {
+		return upload{}, &requestError{"Attachment too large: " + filename + ".", http.StatusRequestEntityTooLarge}
+	}
+	if len(content) == 0 {
+		return upload{}, &requestError{"Empty attachment: " + filename + ".", http.StatusBadRequest}
+	}
+
+	// The extension alone is not trusted, e.g. an executable renamed to .pdf
+	if http.DetectContentType(content) != allowed.Sniffed {
+		return upload{}, &requestError{"Attachment content does not match its type: " + filename + ".", http.StatusUnsupportedMediaType}
+	}
+
+	hash := sha256.Sum256(content)
+	return upload{Filename: filename, ContentType: allowed.ContentType, Content: content, Hash: hex.EncodeToString(hash[:])}, nil
+}
+
+// @Desc: [Attachments] Write the error response of a failed request decode.
+func requestErrorResponse(err error, w http.ResponseWriter) {
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		ErrorResponse(requestErr.Message, w, requestErr.Status)
+		return
+	}
+	ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Attachments] Store the uploads, only once per distinct content, and record them against the notification.
+func saveAttachments(db *sql.DB, notificationId int64, uploads []upload) ([]model.Attachment, error) {
+	store := currentBlobStore()
+	attachments := make([]model.Attachment, 0, len(uploads))
+	for _, upload := range uploads {
+		exists, err := store.Exists(upload.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			if err := store.Put(upload.Hash, bytes.NewReader(upload.Content)); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := db.Exec("INSERT INTO Attachment(notification_id, filename, content_type, size, sha256) VALUES(?, ?, ?, ?, ?)",
+			notificationId, upload.Filename, upload.ContentType, len(upload.Content), upload.Hash)
+		if err != nil {
+			return nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, model.Attachment{
+			Id:          id,
+			Filename:    upload.Filename,
+			ContentType: upload.ContentType,
+			Size:        int64(len(upload.Content)),
+			SHA256:      upload.Hash,
+		})
+	}
+	return attachments, nil
+}
+
+// @Desc: [Attachments] The attachments of a notification, in upload order.
+func getAttachments(db *sql.DB, notificationId int64) ([]model.Attachment, error) {
+	rows, err := db.Query("SELECT id, filename, content_type, size, sha256 FROM Attachment WHERE notification_id = ? ORDER BY id", notificationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		var attachment model.Attachment
+		if err := rows.Scan(&attachment.Id, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.SHA256); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// @Desc: [Attachments] Copies of the attachments with download URLs signed for the recipient, a student or the sending teacher.
+func signAttachments(attachments []model.Attachment, recipient string) []model.Attachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+	expires := time.Now().Add(config.AttachmentURLTTL).Unix()
+	signed := make([]model.Attachment, len(attachments))
+	for i, attachment := range attachments {
+		attachment.URL = attachmentURL(attachment.Id, recipient, expires)
+		signed[i] = attachment
+	}
+	return signed
+}
+
+// @Desc: [Attachments] Download URL of an attachment for the recipient, valid until `expires` (Unix seconds).
+func attachmentURL(id int64, recipient string, expires int64) string {
+	query := url.Values{}
+	query.Set("recipient", recipient)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", attachmentSignature(id, recipient, expires))
+	return config.PublicBaseURL + "/api/attachments/" + strconv.FormatInt(id, 10) + "?" + query.Encode()
+}
+
+func attachmentSignature(id int64, recipient string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.AttachmentURLSecret))
+	fmt.Fprintf(mac, "%d\n%s\n%d", id, recipient, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// @Desc: [Attachments] Whether the signature was issued for this attachment, recipient & expiry.
+func validAttachmentSignature(id int64, recipient string, expires int64, signature string) bool {
+	return len(signature) > 0 && hmac.Equal([]byte(signature), []byte(attachmentSignature(id, recipient, expires)))
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Attachments
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: Build a multipart notification request with the given attachments, keyed by filename.
+func newMultipartNotificationRequest(t *testing.T, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("request", `{"teacher": "t1@gmail.com", "notification": "Worksheet for today"}`)
+	for filename, content := range files {
+		part, err := form.CreateFormFile("attachments", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	form.Close()
+
+	req, err := http.NewRequest("POST", "/api/retrievefornotifications", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+// @Desc: [FAIL] Attaching files of a type that is not allowed, or whose content does not match their extension, which should fail with HTTP code 415.
+func TestRetrieveForNotificationUnsupportedAttachment(t *testing.T) {
+	cases := []struct {
+		filename string
+		content  []byte
+		expected string
+	}{
+		{"setup.exe", []byte("MZ\x90\x00"), `{"message":"Unsupported attachment type: setup.exe."}`},
+		{"worksheet.pdf", []byte("MZ\x90\x00 not really a pdf"), `{"message":"Attachment content does not match its type: worksheet.pdf."}`},
+	}
+
+	for _, c := range cases {
+		req := newMultipartNotificationRequest(t, map[string][]byte{c.filename: c.content})
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(RetrieveForNotification)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code, "Status code should be 415")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestRetrieveForNotificationUnsupportedAttachment")
+}
+
+// @Desc: [FAIL] Attaching more files than allowed, which should fail with HTTP code 400.
+func TestRetrieveForNotificationTooManyAttachments(t *testing.T) {
+	files := make(map[string][]byte)
+	for i := 0; i < 6; i++ {
+		files["notes"+strconv.Itoa(i)+".txt"] = []byte("notes")
+	}
+	req := newMultipartNotificationRequest(t, files)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RetrieveForNotification)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"At most 5 attachments are allowed."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRetrieveForNotificationTooManyAttachments")
+}
+
+// @Desc: [FAIL] Downloading an attachment with a URL signed for another recipient or that has expired, which should fail with HTTP code 403.
+func TestDownloadAttachmentSignature(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	assert.True(t, validAttachmentSignature(1, "s1@gmail.com", expires, attachmentSignature(1, "s1@gmail.com", expires)), "Signature should be valid.")
+
+	expired := time.Now().Add(-time.Hour).Unix()
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"recipient=s2%40gmail.com&expires=" + strconv.FormatInt(expires, 10) + "&signature=" + attachmentSignature(1, "s1@gmail.com", expires), `{"message":"Invalid attachment signature."}`},
+		{"recipient=s1%40gmail.com&expires=" + strconv.FormatInt(expired, 10) + "&signature=" + attachmentSignature(1, "s1@gmail.com", expired), `{"message":"Attachment link has expired."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", "/api/attachments/1?"+c.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(DownloadAttachment)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusForbidden, rr.Code, "Status code should be 403")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestDownloadAttachmentSignature")
+}
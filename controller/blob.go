@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+)
+
+// BlobStore: Content addressed storage of attachment bytes, keyed by the hex SHA-256 of the content
+type BlobStore interface {
+	Exists(key string) (bool, error)
+	Put(key string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+}
+
+var (
+	blobStoreMu sync.RWMutex
+	blobStore   BlobStore = NewFilesystemBlobStore(config.BlobStorageDir)
+)
+
+// @Desc: Store attachments in another BlobStore than the filesystem default. Call during start up.
+func UseBlobStore(store BlobStore) {
+	blobStoreMu.Lock()
+	defer blobStoreMu.Unlock()
+	blobStore = store
+}
+
+// @Desc: [Attachments] The BlobStore in use.
+func currentBlobStore() BlobStore {
+	blobStoreMu.RLock()
+	defer blobStoreMu.RUnlock()
+	return blobStore
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Filesystem Blob Store
+//////////////////////////////////////////////////////////////*/
+
+// FilesystemBlobStore: Keeps each blob as a file under Root, fanned out into directories by the first two characters of its key
+type FilesystemBlobStore struct {
+	Root string
+}
+
+func NewFilesystemBlobStore(root string) *FilesystemBlobStore {
+	return &FilesystemBlobStore{Root: root}
+}
+
+func (s *FilesystemBlobStore) Exists(key string) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// @Desc: [FilesystemBlobStore] Write to a temporary file first so that a blob is never seen half written.
+func (s *FilesystemBlobStore) Put(key string, content io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func (s *FilesystemBlobStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// @Desc: [FilesystemBlobStore] Path of a blob, rejecting keys that are not a hex SHA-256 so that no key can escape Root.
+func (s *FilesystemBlobStore) path(key string) (string, error) {
+	if !isBlobKey(key) {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.Root, key[:2], key), nil
+}
+
+// @Desc: [Attachments] Whether the key is a lowercase hex SHA-256.
+func isBlobKey(key string) bool {
+	if len(key) != 64 {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Filesystem Blob Store
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Blobs should be readable back once put, and keys that are not a SHA-256 should never reach the filesystem.
+func TestFilesystemBlobStore(t *testing.T) {
+	store := NewFilesystemBlobStore(t.TempDir())
+	key := strings.Repeat("ab", 32)
+
+	exists, err := store.Exists(key)
+	assert.NoError(t, err)
+	assert.False(t, exists, "Blob should not exist before being put.")
+
+	assert.NoError(t, store.Put(key, strings.NewReader("worksheet")))
+	exists, err = store.Exists(key)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Blob should exist once put.")
+
+	content, err := store.Open(key)
+	assert.NoError(t, err)
+	read, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "worksheet", string(read), "Blob content should round trip.")
+
+	for _, invalid := range []string{"../../etc/passwd", strings.Repeat("AB", 32), "abc"} {
+		_, err := store.Open(invalid)
+		assert.Error(t, err, "Key %q should be rejected.", invalid)
+	}
+	log.Println("SUCCESS: TestFilesystemBlobStore")
+}
@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// A notification on its way to one student through a channel. Digests have no NotificationId or Teacher and come with a Subject & HTML.
+// Notifications for a guardian have no Student, but the Guardian and the students they are about as Wards.
+type channelMessage struct {
+	NotificationId int64
+	Teacher        string
+	Student        string
+	Guardian       *model.Guardian
+	Wards          []string
+	Text           string
+	Subject        string
+	HTML           string
+	Attachments    []model.Attachment
+}
+
+// Channel: A way of pushing a notification to a student on top of the record kept in their inbox
+type Channel interface {
+	Name() string
+	Send(db *sql.DB, message channelMessage) error
+}
+
+var (
+	channelsMu sync.RWMutex
+	channels   []Channel
+)
+
+/*///////////////////////////////////////////////////////////////
+                          Channel Registry
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: Make a channel available to every notification sent from now on. Call during start up.
+func RegisterChannel(channel Channel) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	channels = append(channels, channel)
+}
+
+// @Desc: [Channels] All registered channels, in order of registration.
+func registeredChannels() []Channel {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	return append([]Channel(nil), channels...)
+}
+
+// @Desc: [Channels] Whether a channel with this name has been registered.
+func isRegisteredChannel(name string) bool {
+	for _, channel := range registeredChannels() {
+		if channel.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// @Desc: [Notifications] Push a recorded notification to the student on every channel they have not muted, returning the channels it went out on.
+// A failing channel is logged and skipped, since the notification is already in the student's inbox.
+func pushNotification(db *sql.DB, message channelMessage, preferences model.StudentPreferences) []string {
+	var sentOn []string
+	for _, channel := range registeredChannels() {
+		if mutesChannel(preferences, channel.Name()) {
+			continue
+		}
+		if err := channel.Send(db, message); err != nil {
+			log.Printf("Failed to send notification %d to %s on %s: %v", message.NotificationId, message.recipient(), channel.Name(), err)
+			continue
+		}
+		sentOn = append(sentOn, channel.Name())
+	}
+	return sentOn
+}
+
+// @Desc: [Channels] Who the message is for, for logging.
+func (m channelMessage) recipient() string {
+	if m.Guardian != nil {
+		return "guardian " + strconv.FormatInt(m.Guardian.Id, 10)
+	}
+	return m.Student
+}
+
+/*///////////////////////////////////////////////////////////////
+                            Email Channel
+//////////////////////////////////////////////////////////////*/
+
+// EmailChannel: Sends notifications to the student's email address over SMTP
+type EmailChannel struct {
+	Address string
+	From    string
+}
+
+// @Desc: Email channel using the SMTP server configured inside `config.go`.
+func NewEmailChannel() *EmailChannel {
+	return &EmailChannel{Address: config.SMTPAddress, From: config.SMTPFrom}
+}
+
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// @Desc: [EmailChannel] Guardians without an email address are skipped.
+func (c *EmailChannel) Send(db *sql.DB, message channelMessage) error {
+	to := emailAddress(message)
+	if len(to) == 0 {
+		return nil
+	}
+	email, err := buildEmail(c.From, message)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(c.Address, nil, c.From, []string{to}, email)
+}
+
+// @Desc: [EmailChannel] The address the message is emailed to, the guardian's for guardians.
+func emailAddress(message channelMessage) string {
+	if message.Guardian != nil {
+		return message.Guardian.Email
+	}
+	return message.Student
+}
+
+// @Desc: [EmailChannel] Email of a notification, plain text or alternatively HTML when the message has one, listing download links of its attachments
+// and with a footer link to unsubscribe from the teacher (or from everything for digests). Addresses going into the headers must be plain email addresses,
+// so that none can add headers of its own, and the subject is encoded for names & text that are not ASCII (RFC 2047).
+func buildEmail(from string, message channelMessage) ([]byte, error) {
+	if len(message.Teacher) > 0 && !plainEmailAddress(message.Teacher) {
+		return nil, errors.New("invalid teacher email address")
+	}
+	if !plainEmailAddress(emailAddress(message)) {
+		return nil, errors.New("invalid recipient email address")
+	}
+
+	subject := message.Subject
+	if len(subject) == 0 {
+		subject = "New notification from " + message.Teacher
+	}
+	footer := "To stop receiving notifications, visit " + unsubscribeURL(message.Student, message.Teacher)
+	if message.Guardian != nil {
+		// Guardians are unlinked by the school rather than unsubscribing themselves
+		if len(message.Subject) == 0 {
+			subject += " about " + strings.Join(message.Wards, ", ")
+		}
+		footer = "You are receiving this as a guardian of " + strings.Join(message.Wards, ", ") + "."
+	} else if len(message.Teacher) > 0 {
+		footer = "To stop receiving notifications from " + message.Teacher + ", visit " + unsubscribeURL(message.Student, message.Teacher)
+	}
+	body := message.Text
+	if len(message.Attachments) > 0 {
+		body += "\n\nAttachments:"
+		for _, attachment := range message.Attachments {
+			body += "\n- " + attachment.Filename + ": " + attachment.URL
+		}
+	}
+	text := strings.ReplaceAll(body, "\n", "\r\n") + "\r\n\r\n--\r\n" + footer + "\r\n"
+
+	var email strings.Builder
+	fmt.Fprintf(&email, "From: %s\r\n", from)
+	fmt.Fprintf(&email, "To: %s\r\n", emailAddress(message))
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	// Email clients unsubscribe students in one click by posting to the link (RFC 8058)
+	if message.Guardian == nil {
+		fmt.Fprintf(&email, "List-Unsubscribe: <%s>\r\n", unsubscribeURL(message.Student, message.Teacher))
+		email.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	email.WriteString("MIME-Version: 1.0\r\n")
+	if len(message.HTML) == 0 {
+		email.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		email.WriteString(text)
+		return []byte(email.String()), nil
+	}
+
+	htmlBody := message.HTML
+	if len(message.Attachments) > 0 {
+		htmlBody += "\r\n<p>Attachments:</p>\r\n<ul>"
+		for _, attachment := range message.Attachments {
+			htmlBody += "\r\n<li><a href=\"" + html.EscapeString(attachment.URL) + "\">" + html.EscapeString(attachment.Filename) + "</a></li>"
+		}
+		htmlBody += "\r\n</ul>"
+	}
+
+	const boundary = "notification-alternative"
+	fmt.Fprintf(&email, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+	fmt.Fprintf(&email, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, text)
+	fmt.Fprintf(&email, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n<hr>\r\n<p>%s</p>\r\n", boundary, htmlBody, html.EscapeString(footer))
+	fmt.Fprintf(&email, "--%s--\r\n", boundary)
+	return []byte(email.String()), nil
+}
+
+// @Desc: [EmailChannel] Whether the value is a bare email address, without a display name or any line break.
+func plainEmailAddress(value string) bool {
+	if strings.ContainsAny(value, "\r\n") {
+		return false
+	}
+	address, err := mail.ParseAddress(value)
+	return err == nil && len(address.Name) == 0 && address.Address == value
+}
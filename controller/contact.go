@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// An E.164 phone number, e.g. +6591234567
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+/*///////////////////////////////////////////////////////////////
+                          Contact Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentContact: Retrieve the contact details of a student, used by channels such as SMS
+// URL : /students/{student}/contact
+// Parameters: student
+// Method: GET
+// Output: JSON Encoded Object of the student's contact details, else error message.
+func StudentContact(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	contact, err := getStudentContact(db, student)
+	if err != nil {
+		ErrorResponse("Failed to retrieve contact details.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(contact)
+}
+
+// UpdateStudentContact: Save the contact details of a student. An empty phone number removes it
+// URL : /students/{student}/contact
+// Parameters: phone
+// Method: PUT
+// Output: JSON Encoded Object of the saved contact details, else error message.
+func UpdateStudentContact(w http.ResponseWriter, r *http.Request) {
+	var contact model.StudentContact
+
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&contact)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	contact.Student = student
+	contact.Phone = normalisePhone(contact.Phone)
+	if len(contact.Phone) > 0 && !validPhoneFormat(contact.Phone) {
+		ErrorResponse("Invalid phone number, expected E.164 e.g. +6591234567.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if len(contact.Phone) == 0 {
+		_, err = db.Exec("DELETE FROM StudentContact WHERE student = ?", student)
+	} else {
+		_, err = db.Exec("INSERT INTO StudentContact(student, phone) VALUES(?, ?) ON DUPLICATE KEY UPDATE phone = VALUES(phone)", student, contact.Phone)
+	}
+	if err != nil {
+		ErrorResponse("Failed to save contact details.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(contact)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Contact] The contact details of a student, with no phone number if none was saved.
+func getStudentContact(db *sql.DB, student string) (model.StudentContact, error) {
+	contact := model.StudentContact{Student: student}
+	err := db.QueryRow("SELECT phone FROM StudentContact WHERE student = ?", student).Scan(&contact.Phone)
+	if err == sql.ErrNoRows {
+		return contact, nil
+	}
+	return contact, err
+}
+
+// @Desc: [Contact] Drop the spaces, dashes & brackets a phone number is commonly written with, e.g. +65 9123-4567.
+func normalisePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+}
+
+// @Desc: [Contact] Whether the phone number is in E.164 format.
+func validPhoneFormat(phone string) bool {
+	return phonePattern.MatchString(phone)
+}
@@ -3,8 +3,10 @@ package controller
 import (
     "encoding/json"
     "fmt"
+    "log"
     "net/mail"
     "strings"
+    "time"
     "net/http"
     "database/sql"
  
@@ -97,6 +99,16 @@ func RegisterStudents(w http.ResponseWriter, r *http.Request) {
     }
 
 
+    // Teachers & students are given a profile the first time they are registered
+    if err := ensureProfiles(db, teacherProfiles, teacher); err != nil {
+        ErrorResponse("Failed to register students.", w, http.StatusNotFound)
+        return
+    }
+    if err := ensureProfiles(db, studentProfiles, students...); err != nil {
+        ErrorResponse("Failed to register students.", w, http.StatusNotFound)
+        return
+    }
+
     // Insert students one by one
     for _, student := range students {
 
@@ -163,7 +175,10 @@ func SuspendStudent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    _, err = db.Exec("INSERT INTO Suspend(student) VALUES(?)", student)
+    err = ensureProfiles(db, studentProfiles, student)
+    if err == nil {
+        _, err = db.Exec("INSERT INTO Suspend(student) VALUES(?)", student)
+    }
     if err != nil {
         ErrorResponse("Failed to suspend student", w, http.StatusNotFound)
         return
@@ -177,7 +192,8 @@ func SuspendStudent(w http.ResponseWriter, r *http.Request) {
 
 // RetrieveForNotification: Retrieve and send notifications to a list of registered, notified and non-suspended students by a teacher
 // URL : /retrievefornotification
-// Parameters: teacher, notification
+// Parameters: teacher, notification, send_at, expires_at, render_mentions, template_id, priority, include_suspended (X-Admin-Token header required), requires_ack,
+// replies_visible, format, language, variants, audience, attachments (multipart/form-data, with the JSON parameters in the "request" field)
 // Method: POST
 // Output: JSON Encoded Object of teacher, notification and list of students notified.
 func RetrieveForNotification(w http.ResponseWriter, r *http.Request) {
@@ -187,79 +203,280 @@ func RetrieveForNotification(w http.ResponseWriter, r *http.Request) {
     defer db.Close()
 
 
-    err := json.NewDecoder(r.Body).Decode(&requestBody)
+    uploads, err := decodeNotificationRequest(w, r, &requestBody)
     if err != nil {
-        ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+        requestErrorResponse(err, w)
         return
     }
 
     var teacher string = requestBody.Teacher
     var notification string = requestBody.Notification
-    words := strings.Split(notification, " ")
+
+    // A saved template of the teacher stands in for the notification text
+    if requestBody.TemplateId != nil {
+        if len(strings.TrimSpace(notification)) > 0 {
+            ErrorResponse("Specify either a notification or a template_id.", w, http.StatusBadRequest)
+            return
+        }
+        saved, err := getTeacherTemplate(db, teacher, *requestBody.TemplateId)
+        if err != nil {
+            templateNotFoundResponse(err, w)
+            return
+        }
+        notification = saved.Body
+    }
+
     // If minimally no words in notification string
-    if len(words[0]) == 0 {
+    if len(strings.TrimSpace(notification)) == 0 {
         ErrorResponse("Empty teacher or notification format.", w, http.StatusBadRequest)
         return
     }
 
-    var notificationWords []string
-    var emails []string
-    // Run a for loop until an '@' sign is found in the string, that marks the first email
-    for _, word := range words {
-        var isEmail bool = isEmailRegisterFormat(word)
-        if !isEmail {
-            notificationWords = append(notificationWords, word)
-        } else {
-            studentEmail := word[1:]
-            emails = append(emails, studentEmail)
+    if !isValidMentionRendering(requestBody.RenderMentions) {
+        ErrorResponse("Invalid render_mentions option.", w, http.StatusBadRequest)
+        return
+    }
+
+    priority := requestBody.Priority
+    if len(priority) == 0 {
+        priority = priorityNormal
+    }
+    if !isValidPriority(priority) {
+        ErrorResponse("Invalid priority, expected low, normal or urgent.", w, http.StatusBadRequest)
+        return
+    }
+
+    sendAt := time.Now()
+    if requestBody.SendAt != nil && requestBody.SendAt.After(sendAt) {
+        sendAt = *requestBody.SendAt
+    }
+    if !validExpiry(requestBody.ExpiresAt, sendAt) {
+        ErrorResponse("Expiry must be after the notification is sent.", w, http.StatusBadRequest)
+        return
+    }
+
+    format := requestBody.Format
+    if len(format) == 0 {
+        format = notificationFormatPlain
+    }
+    if !isValidFormat(format) {
+        ErrorResponse("Invalid format, expected plain or markdown.", w, http.StatusBadRequest)
+        return
+    }
+
+    language := normaliseLanguage(requestBody.Language)
+    if len(language) == 0 {
+        language = config.FallbackLanguage
+    }
+    if !isValidLanguage(language) {
+        ErrorResponse("Invalid language.", w, http.StatusBadRequest)
+        return
+    }
+    audience := requestBody.Audience
+    if len(audience) == 0 {
+        audience = audienceStudents
+    }
+    if !isValidAudience(audience) {
+        ErrorResponse("Invalid audience, expected students, guardians or both.", w, http.StatusBadRequest)
+        return
+    }
+
+    variants, err := validateVariants(requestBody.Variants, language, requestBody.TemplateId != nil)
+    if err != nil {
+        ErrorResponse(err.Error(), w, http.StatusBadRequest)
+        return
+    }
+
+    // Suspended students are only ever reached by urgent notifications an admin signed off on
+    if requestBody.IncludeSuspended {
+        if priority != priorityUrgent {
+            ErrorResponse("Only urgent notifications can reach suspended students.", w, http.StatusBadRequest)
+            return
         }
+        if !isAdminRequest(r) {
+            ErrorResponse("Admin authorisation required to reach suspended students.", w, http.StatusForbidden)
+            return
+        }
+    }
+
+    // Content filters run before anything is recorded, so that a redacted notification is sent & stored redacted
+    original := notification
+    notification, moderation := moderateContent(notification)
+    variants, variantModerations, variantBlocked := moderateVariants(variants)
+    if moderation.Outcome == moderationBlock || variantBlocked {
+        err := recordModeration(db, teacher, nil, nil, original, moderation)
+        if err == nil {
+            err = recordVariantModeration(db, teacher, nil, variantModerations)
+        }
+        if err != nil {
+            ErrorResponse("Failed to moderate notification.", w, http.StatusNotFound)
+            return
+        }
+        writeModerationBlocked(mergeModeration(moderation, variantModerations), w)
+        return
     }
+
+    active, err := teacherActive(db, teacher)
+    if err != nil {
+        ErrorResponse("Failed to retrieve teacher.", w, http.StatusNotFound)
+        return
+    }
+    if !active {
+        ErrorResponse("Teacher is inactive.", w, http.StatusForbidden)
+        return
+    }
+
+    // Scheduled notifications count against the quota when they are requested, so a teacher cannot queue up more than they could send
+    limits, _, err := getRateLimits(db, teacher)
+    if err != nil {
+        ErrorResponse("Failed to retrieve rate limits.", w, http.StatusNotFound)
+        return
+    }
+    if retryAfter, exceeded := notificationLimiter.reserveNotification(teacher, limits, time.Now()); retryAfter > 0 {
+        rateLimitedResponse(retryAfter, exceeded, w)
+        return
+    }
+    // What was reserved is given back unless the notification is scheduled or sent, along with the recipients it was not recorded against
+    kept := false
+    reservedRecipients, recordedRecipients := 0, 0
+    defer func() {
+        if !kept {
+            notificationLimiter.refundNotification(teacher, limits, time.Now())
+        }
+        notificationLimiter.settleRecipients(teacher, limits, reservedRecipients, recordedRecipients, time.Now())
+    }()
+
+    mentions, emails, invalidMentions := extractMentions(notification)
     
     // Add student emails to notification
-   if len(emails) > 0 {
-        for _, email := range emails {
-            // Check if the email has been registered under the teacher:
-            err = insertStudentIfNotExists(db, teacher, email)
-            if err != nil {
-                ErrorResponse("Failed to register student emails for notifications", w, http.StatusNotFound)
-                return
-            }
+    err = registerMentions(db, teacher, emails)
+    if err != nil {
+        ErrorResponse("Failed to register student emails for notifications", w, http.StatusNotFound)
+        return
+    }
+
+    // Scheduled notifications are persisted and only resolved for recipients when they fall due
+    if requestBody.SendAt != nil && requestBody.SendAt.After(time.Now()) {
+        scheduled, err := createNotification(db, model.ScheduledNotification{Teacher: teacher, Notification: notification, Status: notificationStatusPending, SendAt: *requestBody.SendAt, TemplateId: requestBody.TemplateId, Priority: priority, IncludeSuspended: requestBody.IncludeSuspended, RequiresAck: requestBody.RequiresAck, RepliesVisible: requestBody.RepliesVisible, Format: format, Language: language, Variants: variants, ExpiresAt: requestBody.ExpiresAt, Audience: audience})
+        if err != nil {
+            ErrorResponse("Failed to schedule notification.", w, http.StatusNotFound)
+            return
         }
-   }
+        // A notification that could not be saved in full is marked failed rather than sent when it falls due
+        err = recordModeration(db, teacher, &scheduled.Id, nil, original, moderation)
+        if err == nil {
+            err = recordVariantModeration(db, teacher, &scheduled.Id, variantModerations)
+        }
+        if err != nil {
+            failNotification(db, scheduled.Id)
+            ErrorResponse("Failed to schedule notification.", w, http.StatusInternalServerError)
+            return
+        }
+        scheduled.Moderation = moderationSummary(mergeModeration(moderation, variantModerations))
+        attachments, err := saveAttachments(db, scheduled.Id, uploads)
+        if err != nil {
+            failNotification(db, scheduled.Id)
+            ErrorResponse("Failed to save attachments.", w, http.StatusInternalServerError)
+            return
+        }
+        scheduled.Attachments = signAttachments(attachments, teacher)
+        kept = true
+        publishDeliveryUpdate(scheduled, nil)
+
+        w.Header().Set("Content-Type", "application/json")
+        w.Header().Set("Access-Control-Allow-Origin", "*")
+        w.WriteHeader(http.StatusAccepted)
+        json.NewEncoder(w).Encode(scheduled)
+        return
+    }
 
-    var viewName string = "RegisteredStudentsForNotifications"
-   err = dropViewIfExists(db, viewName)
-   if err != nil {
-        ErrorResponse("Failed to retrieve notifications.", w, http.StatusNotFound)
-        return;
-   }
-
-   // 1. Create VIEW of all students under the specified teacher that are registered for notifications & also t side of :=gopreviously registered under teaching.
-   var viewQuery string = fmt.Sprintf(`CREATE VIEW RegisteredStudentsForNotifications AS 
-   SELECT Notification.student
-   FROM Teach, Notification 
-   WHERE Teach.teacher = Notification.teacher AND Teach.student = Notification.student AND Notification.teacher = '%s'`, teacher)
-   _, err = db.Exec(viewQuery)
-   if err != nil {
+    // Recipients are charged to the quota before anything is recorded, so that one notification cannot go over it
+    sending := model.ScheduledNotification{Teacher: teacher, Notification: notification, Status: notificationStatusSent, SendAt: time.Now(), TemplateId: requestBody.TemplateId, Priority: priority, IncludeSuspended: requestBody.IncludeSuspended, RequiresAck: requestBody.RequiresAck, RepliesVisible: requestBody.RepliesVisible, Format: format, Language: language, Variants: variants, ExpiresAt: requestBody.ExpiresAt, Audience: audience}
+    recipients, err := resolveRecipients(db, sending)
+    if err != nil {
+        ErrorResponse("Failed to retrieve students for notifications.", w, http.StatusNotFound)
+        return
+    }
+    newRecipients, err := countNewRecipients(db, sending, recipients.Students)
+    if err != nil {
+        ErrorResponse("Failed to retrieve students for notifications.", w, http.StatusNotFound)
+        return
+    }
+    if newRecipients > limits.RecipientsPerDay {
+        ErrorResponse(fmt.Sprintf("Notification has more recipients than the limit of %d per day.", limits.RecipientsPerDay), w, http.StatusForbidden)
+        return
+    }
+    if retryAfter, exceeded := notificationLimiter.reserveRecipients(teacher, limits, newRecipients, time.Now()); retryAfter > 0 {
+        rateLimitedResponse(retryAfter, exceeded, w)
+        return
+    }
+    reservedRecipients = newRecipients
+
+    sent, err := createNotification(db, sending)
+    if err != nil {
         ErrorResponse("Failed to retrieve notifications.", w, http.StatusNotFound)
         return
     }
+    // Nothing is delivered until the notification is fully saved, so one that fails before then is marked failed rather than left as sent
+    err = recordModeration(db, teacher, &sent.Id, nil, original, moderation)
+    if err == nil {
+        err = recordVariantModeration(db, teacher, &sent.Id, variantModerations)
+    }
+    if err != nil {
+        failNotification(db, sent.Id)
+        ErrorResponse("Failed to save notification.", w, http.StatusInternalServerError)
+        return
+    }
 
-   // 2. Retrieve all students from RegisteredStudentsForNotifcations - SuspendedStudents
-   rows, err := db.Query("SELECT student FROM RegisteredStudentsForNotifications WHERE student NOT IN (SELECT student FROM Suspend)")
-   if err != nil {
-        ErrorResponse("Failed to retrieve students for notifications.", w, http.StatusNotFound)
+    // Attachments are saved before delivery so that every recipient gets their links
+    attachments, err := saveAttachments(db, sent.Id, uploads)
+    if err != nil {
+        failNotification(db, sent.Id)
+        ErrorResponse("Failed to save attachments.", w, http.StatusInternalServerError)
         return
     }
-    
-    var studentStruct model.Student
+
+    // A delivery failing partway is released to the scheduler like a scheduled one, which retries it without repeating it for the recipients already reached
+    delivered, err := deliverNotification(db, sent, recipients)
+    recordedRecipients = delivered.Recorded
+    if err != nil {
+        // Sent by the scheduler's retry, which reserves the recipients not reached yet itself
+        kept = true
+        log.Printf("Failed to deliver notification %d: %v", sent.Id, err)
+        if err := releaseFailedDelivery(db, sent.Id); err != nil {
+            log.Printf("Failed to release notification %d for retry: %v", sent.Id, err)
+        }
+        ErrorResponse("Failed to deliver notification, it will be retried.", w, http.StatusInternalServerError)
+        return
+    }
+
+    kept = true
+
     var notificationResponse model.RetrieveForNotificationResponse
     notificationResponse.Teacher = teacher
-    notificationResponse.Notification = strings.Join(notificationWords, " ")
- 
-    for rows.Next() {
-        err = rows.Scan(&studentStruct.StudentEmail)
-        notificationResponse.Students = append(notificationResponse.Students, studentStruct.StudentEmail)
+    notificationResponse.Notification = sent.Notification
+    notificationResponse.Mentions = mentionSpans(notification, mentions)
+    if len(requestBody.RenderMentions) > 0 {
+        names, err := studentNames(db, emails)
+        if err != nil {
+            ErrorResponse("Failed to retrieve students for notifications.", w, http.StatusNotFound)
+            return
+        }
+        notificationResponse.RenderedNotification = renderMentions(notification, mentions, requestBody.RenderMentions, names)
+    }
+    notificationResponse.Students = delivered.Students
+    // Students are only listed when they were notified themselves
+    if audience == audienceGuardians {
+        notificationResponse.Students = make([]string, 0)
+    }
+    notificationResponse.RecipientBreakdown = delivered.Breakdown
+    notificationResponse.Guardians = delivered.Guardians
+    notificationResponse.RenderedMessages = delivered.Messages
+    notificationResponse.InvalidMentions = invalidMentions
+    notificationResponse.Attachments = signAttachments(attachments, teacher)
+    notificationResponse.Moderation = moderationSummary(mergeModeration(moderation, variantModerations))
+    if format == notificationFormatMarkdown {
+        notificationResponse.Text, notificationResponse.HTML = renderNotificationBody(notification, format)
     }
 
     if len(notificationResponse.Students) == 0 {
@@ -305,11 +522,26 @@ func validEmailFormat(email string) bool {
     return err == nil
 }
 
-func isEmailRegisterFormat(text string) bool {
-    if len(text) > 0 && text[0] =='@' {
-        return true
+// @Desc: [RetrieveForNotification] Tokenize a notification into its mentions, the unique mentioned student emails and any invalid mentions.
+func extractMentions(notification string) ([]mention, []string, []string) {
+    mentions, invalid := parseMentions(notification)
+
+    var invalidMentions []string
+    for _, m := range invalid {
+        invalidMentions = append(invalidMentions, m.Text)
     }
-    return false
+    return mentions, mentionedEmails(mentions), invalidMentions
+}
+
+// @Desc: [RetrieveForNotification] Register every mentioned student for notifications by the teacher.
+func registerMentions(db *sql.DB, teacher string, emails []string) error {
+    for _, email := range emails {
+        // Check if the email has been registered under the teacher:
+        if err := insertStudentIfNotExists(db, teacher, email); err != nil {
+            return err
+        }
+    }
+    return nil
 }
 
 // @Desc:[CommonStudent] To dynamically build the query based on the teachers specified in `CommonStudent` Query
@@ -339,25 +571,40 @@ func insertStudentIfNotExists(db *sql.DB, teacherEmail string,  studentEmail str
         return err
     }
 
+    if err := ensureProfiles(db, teacherProfiles, teacherEmail); err != nil {
+        return err
+    }
+    if err := ensureProfiles(db, studentProfiles, studentEmail); err != nil {
+        return err
+    }
+
     query := `INSERT INTO Notification (teacher, student) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM Notification WHERE teacher = ? AND student = ?)`
     _, err = db.Exec(query, teacherEmail, studentEmail, teacherEmail, studentEmail)
     return err
 }
 
 
-// @Desc: [RetrieveForNotification] Helper function to drop intermediate `RegisteredStudentsForNotifications` View table if exists.
-func dropViewIfExists(db *sql.DB, viewName string) error {
-    var exists bool
-    query := fmt.Sprintf("SELECT COUNT(*) FROM information_schema.views WHERE table_name = '%s'", viewName)
-    err := db.QueryRow(query).Scan(&exists)
+// @Desc: [RetrieveForNotification] Helper function to retrieve all students under the specified teacher that are registered for notifications, previously registered under teaching, not suspended (unless an admin allowed it), not opted out of the teacher's notifications & not inactive.
+func getStudentsForNotification(db *sql.DB, teacher string, includeSuspended bool) ([]string, error) {
+    rows, err := db.Query(`SELECT Notification.student
+    FROM Teach, Notification
+    WHERE Teach.teacher = Notification.teacher AND Teach.student = Notification.student AND Notification.teacher = ?
+    AND (? OR Notification.student NOT IN (SELECT student FROM Suspend))
+    AND Notification.student NOT IN (SELECT student FROM StudentPreference WHERE opted_out = 1)
+    AND Notification.student NOT IN (SELECT student FROM TeacherOptOut WHERE teacher = ?)
+    AND Notification.student NOT IN (SELECT email FROM Student WHERE status <> 'active')`, teacher, includeSuspended, teacher)
     if err != nil {
-        return err
+        return nil, err
     }
-    if exists {
-        _, err := db.Exec(fmt.Sprintf("DROP VIEW %s", viewName))
-        if err != nil {
-            return err
+    defer rows.Close()
+
+    var students []string
+    for rows.Next() {
+        var student string
+        if err := rows.Scan(&student); err != nil {
+            return nil, err
         }
+        students = append(students, student)
     }
-    return nil
-}
\ No newline at end of file
+    return students, rows.Err()
+}
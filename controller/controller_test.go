@@ -9,6 +9,8 @@ import (
     _ "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"log"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
 )
 
  /*///////////////////////////////////////////////////////////////
@@ -314,7 +316,7 @@ func TestRetrieveForNotications(t *testing.T) {
 	status := rr.Code
 
 	// Check the response body is what we expect.
-	expected := `{"teacher":"t1@gmail.com","notification":"hello world bye","students":["s2@gmail.com","s3@gmail.com"]}`
+	expected := `{"teacher":"t1@gmail.com","notification":"hello world bye @s1@gmail.com @s2@gmail.com @s3@gmail.com","mentions":[{"offset":16,"length":13,"email":"s1@gmail.com"},{"offset":30,"length":13,"email":"s2@gmail.com"},{"offset":44,"length":13,"email":"s3@gmail.com"}],"students":["s2@gmail.com","s3@gmail.com"]}`
 	actual := strings.TrimRight(rr.Body.String(), "\n")
 
 
@@ -381,3 +383,63 @@ func TestRetrieveForNoticationsMissingNotificationField(t *testing.T) {
 
 
 
+
+
+// @Desc: [FAIL] Retrieving students for notifications with an unknown priority. This query should fail with HTTP code 400.
+func TestRetrieveForNoticationsInvalidPriority(t *testing.T) {
+	var jsonBody = []byte(`{"teacher": "t1@gmail.com", "notification": "Fire drill at 10am", "priority": "critical"}`)
+	req, err := http.NewRequest("POST", "/api/retrievefornotifications", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RetrieveForNotification)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid priority, expected low, normal or urgent."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, status, http.StatusBadRequest, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRetrieveForNoticationsInvalidPriority")
+}
+
+
+// @Desc: [FAIL] Reaching suspended students with an urgent notification without the admin token, or with a non-urgent notification. These queries should fail with HTTP code 403 & 400 respectively.
+func TestRetrieveForNoticationsIncludeSuspendedUnauthorised(t *testing.T) {
+	cases := []struct {
+		body     string
+		token    string
+		status   int
+		expected string
+	}{
+		{`{"teacher": "t1@gmail.com", "notification": "School closed today", "priority": "urgent", "include_suspended": true}`, "", http.StatusForbidden, `{"message":"Admin authorisation required to reach suspended students."}`},
+		{`{"teacher": "t1@gmail.com", "notification": "School closed today", "priority": "urgent", "include_suspended": true}`, "wrong-token", http.StatusForbidden, `{"message":"Admin authorisation required to reach suspended students."}`},
+		{`{"teacher": "t1@gmail.com", "notification": "School closed today", "include_suspended": true}`, config.AdminToken, http.StatusBadRequest, `{"message":"Only urgent notifications can reach suspended students."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("POST", "/api/retrievefornotifications", bytes.NewBuffer([]byte(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(c.token) > 0 {
+			req.Header.Set("X-Admin-Token", c.token)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(RetrieveForNotification)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, c.status, rr.Code, "Status code should match.")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestRetrieveForNoticationsIncludeSuspendedUnauthorised")
+}
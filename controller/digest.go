@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const (
+	digestOff    = "off"
+	digestHourly = "hourly"
+	digestDaily  = "daily"
+)
+
+const (
+	defaultDigestLimit = 10
+	maxDigestLimit     = 50
+)
+
+// A notification summarised in a digest
+type digestItem struct {
+	NotificationId int64
+	Teacher        string
+	Notification   string
+	SentAt         time.Time
+}
+
+// Fields available to the digest templates
+type digestView struct {
+	Frequency string
+	Items     []digestItem
+}
+
+var digestTextTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{"sentAt": formatDigestTime}).Parse(
+	`Your {{.Frequency}} digest of {{len .Items}} notification{{if ne (len .Items) 1}}s{{end}}
+{{range .Items}}
+[{{sentAt .SentAt}}] {{.Teacher}}
+{{.Notification}}
+{{end}}`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Funcs(htmltemplate.FuncMap{"sentAt": formatDigestTime}).Parse(
+	`<h2>Your {{.Frequency}} digest of {{len .Items}} notification{{if ne (len .Items) 1}}s{{end}}</h2>
+<ul>
+{{- range .Items}}
+<li><p><strong>{{.Teacher}}</strong> <small>{{sentAt .SentAt}}</small></p><p style="white-space: pre-line">{{.Notification}}</p></li>
+{{- end}}
+</ul>`))
+
+/*///////////////////////////////////////////////////////////////
+                            Digest Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentDigests: List the latest digests built for a student, newest first
+// URL : /students/{student}/digests
+// Parameters: student, limit
+// Method: GET
+// Output: JSON Encoded Array of digests, else error message.
+func StudentDigests(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultDigestLimit
+	if limitParam := r.URL.Query().Get("limit"); len(limitParam) > 0 {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxDigestLimit {
+			ErrorResponse("Invalid limit.", w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, student, frequency, period_end, notifications, text, html, created_at FROM Digest
+	WHERE student = ? ORDER BY created_at DESC, id DESC LIMIT ?`, student, limit)
+	if err != nil {
+		ErrorResponse("Failed to get digests.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	digests := make([]model.Digest, 0)
+	for rows.Next() {
+		var digest model.Digest
+		err := rows.Scan(&digest.Id, &digest.Student, &digest.Frequency, &digest.PeriodEnd, &digest.Notifications, &digest.Text, &digest.HTML, &digest.CreatedAt)
+		if err != nil {
+			ErrorResponse("Failed to get digests.", w, http.StatusNotFound)
+			return
+		}
+		digests = append(digests, digest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(digests)
+}
+
+/*///////////////////////////////////////////////////////////////
+                            Digest Builder
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Digest] Hold back pushing a notification to a student in digest mode until their next digest.
+func queueDigestEntry(db *sql.DB, notificationId int64, student string, frequency string) error {
+	_, err := db.Exec("INSERT IGNORE INTO DigestEntry(notification_id, student, frequency, created_at) VALUES(?, ?, ?, ?)", notificationId, student, frequency, time.Now().UTC())
+	return err
+}
+
+// @Desc: [Scheduler] Build and push a digest for every student with notifications queued before the end of their last digest period.
+// Students without digests, who switched them off since or were sent low priority notifications, have what is queued sent at the end of the period it was queued for.
+func buildDueDigests(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`SELECT DigestEntry.student, COALESCE(MAX(StudentPreference.digest), ?), MIN(DigestEntry.frequency)
+	FROM DigestEntry LEFT JOIN StudentPreference ON StudentPreference.student = DigestEntry.student
+	WHERE DigestEntry.digest_id IS NULL GROUP BY DigestEntry.student`, digestOff)
+	if err != nil {
+		return err
+	}
+
+	type pendingStudent struct{ student, frequency, queuedFrequency string }
+	var pending []pendingStudent
+	for rows.Next() {
+		var p pendingStudent
+		if err := rows.Scan(&p.student, &p.frequency, &p.queuedFrequency); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+
+	location, err := time.LoadLocation(config.DigestTimezone)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		preferences, err := getStudentPreferences(db, p.student)
+		if err != nil {
+			return err
+		}
+		// Digests wait for quiet hours to pass, like any other push
+		if inQuietHours(preferences, now) {
+			continue
+		}
+		if p.frequency == digestOff {
+			p.frequency = p.queuedFrequency
+		}
+		periodEnd := digestPeriodEnd(p.frequency, now, location)
+		if err := buildDigest(db, preferences, p.frequency, periodEnd, now); err != nil {
+			log.Printf("Failed to build digest for %s: %v", p.student, err)
+		}
+	}
+	return nil
+}
+
+// @Desc: [Digest] Summarise a student's notifications queued before `periodEnd` and not yet expired at `now` into one digest, then push it on their channels.
+func buildDigest(db *sql.DB, preferences model.StudentPreferences, frequency string, periodEnd time.Time, now time.Time) error {
+	rows, err := db.Query(`SELECT Message.id, Message.teacher, COALESCE(Recipient.message, Message.message), Message.format, Message.sent_at
+	FROM DigestEntry
+	JOIN NotificationMessage AS Message ON Message.id = DigestEntry.notification_id
+	JOIN NotificationRecipient AS Recipient ON Recipient.notification_id = DigestEntry.notification_id AND Recipient.student = DigestEntry.student
+	WHERE DigestEntry.student = ? AND DigestEntry.digest_id IS NULL AND DigestEntry.created_at < ? AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?)
+	ORDER BY Message.sent_at, Message.id`, preferences.Student, periodEnd.UTC(), notificationStatusSent, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	var items []digestItem
+	for rows.Next() {
+		var item digestItem
+		var format string
+		if err := rows.Scan(&item.NotificationId, &item.Teacher, &item.Notification, &format, &item.SentAt); err != nil {
+			rows.Close()
+			return err
+		}
+		item.Notification = notificationText(item.Notification, format)
+		items = append(items, item)
+	}
+	rows.Close()
+	if len(items) == 0 {
+		return nil
+	}
+
+	text, html, err := renderDigest(frequency, items)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO Digest(student, frequency, period_end, notifications, text, html, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		preferences.Student, frequency, periodEnd.UTC(), len(items), text, html, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	digestId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		_, err = tx.Exec("UPDATE DigestEntry SET digest_id = ? WHERE notification_id = ? AND student = ?", digestId, item.NotificationId, preferences.Student)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	pushNotification(db, channelMessage{
+		Student: preferences.Student,
+		Subject: "Your " + frequency + " notification digest",
+		Text:    text,
+		HTML:    html,
+	}, preferences)
+	return nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Digest] Whether the frequency is one a student can choose.
+func isDigestFrequency(frequency string) bool {
+	return frequency == digestOff || frequency == digestHourly || frequency == digestDaily
+}
+
+// @Desc: [Digest] End of the latest digest period at `now`: the start of the hour for hourly digests, the latest DigestDailyHour for daily digests, and `now` itself once digests are off.
+func digestPeriodEnd(frequency string, now time.Time, location *time.Location) time.Time {
+	switch frequency {
+	case digestHourly:
+		return now.Truncate(time.Hour)
+	case digestDaily:
+		local := now.In(location)
+		end := time.Date(local.Year(), local.Month(), local.Day(), config.DigestDailyHour, 0, 0, 0, location)
+		if end.After(now) {
+			end = end.AddDate(0, 0, -1)
+		}
+		return end
+	default:
+		return now
+	}
+}
+
+// @Desc: [Digest] Render a digest as plain text & HTML, with notification text escaped in the HTML.
+func renderDigest(frequency string, items []digestItem) (string, string, error) {
+	view := digestView{Frequency: frequency, Items: items}
+
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, view); err != nil {
+		return "", "", err
+	}
+	if err := digestHTMLTemplate.Execute(&html, view); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// @Desc: [Digest] Send times are shown in DigestTimezone.
+func formatDigestTime(t time.Time) string {
+	location, err := time.LoadLocation(config.DigestTimezone)
+	if err != nil {
+		location = time.UTC
+	}
+	return t.In(location).Format("2 Jan 15:04")
+}
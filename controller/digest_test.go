@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Digests
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Hourly digests should cover up to the start of the hour, daily digests up to the latest digest hour in the digest timezone.
+func TestDigestPeriodEnd(t *testing.T) {
+	singapore, err := time.LoadLocation("Asia/Singapore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 2, 15, 9, 41, 0, 0, singapore)
+	assert.Equal(t, time.Date(2023, 2, 15, 9, 0, 0, 0, singapore), digestPeriodEnd(digestHourly, now, singapore).In(singapore), "Hourly digests should end on the hour.")
+	assert.Equal(t, now, digestPeriodEnd(digestOff, now, singapore), "Digests switched off should flush everything.")
+
+	// 09:41 is before the daily digest hour, so the period ended on the previous day
+	daily := digestPeriodEnd(digestDaily, now, singapore)
+	assert.False(t, daily.After(now), "Daily period should not end in the future.")
+	assert.True(t, now.Sub(daily) < 24*time.Hour, "Daily period should have ended within the last day.")
+	assert.Equal(t, 0, daily.Minute(), "Daily period should end on the hour.")
+	log.Println("SUCCESS: TestDigestPeriodEnd")
+}
+
+// @Desc: [VALID] Digests should list every notification in plain text, and escape notification text in HTML.
+func TestRenderDigest(t *testing.T) {
+	sentAt := time.Date(2023, 2, 15, 1, 0, 0, 0, time.UTC)
+	items := []digestItem{
+		{NotificationId: 1, Teacher: "t1@gmail.com", Notification: "Quiz on <Friday>", SentAt: sentAt},
+		{NotificationId: 2, Teacher: "t2@gmail.com", Notification: "Bring PE attire", SentAt: sentAt.Add(time.Hour)},
+	}
+
+	text, html, err := renderDigest(digestDaily, items)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "Your daily digest of 2 notifications\n"), "Text should start with a summary.")
+	assert.Contains(t, text, "t1@gmail.com\nQuiz on <Friday>\n", "Text should keep the notification as is.")
+	assert.Contains(t, text, "t2@gmail.com\nBring PE attire\n", "Text should list every notification.")
+	assert.Contains(t, html, "Quiz on &lt;Friday&gt;", "HTML should escape the notification.")
+	assert.NotContains(t, html, "<Friday>", "HTML should not contain unescaped notification text.")
+
+	text, _, err = renderDigest(digestHourly, items[:1])
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "Your hourly digest of 1 notification\n"), "A single notification should not be pluralised.")
+	log.Println("SUCCESS: TestRenderDigest")
+}
+
+// @Desc: [FAIL] Listing digests with a limit above the maximum, which should fail with HTTP code 400.
+func TestStudentDigestsInvalidLimit(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/students/s1@gmail.com/digests?limit=51", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(StudentDigests)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid limit."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestStudentDigestsInvalidLimit")
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Tables holding rows of a notification, purged along with it. Content moderation records are kept as an audit trail
+var notificationTables = []string{"NotificationRecipient", "DigestEntry", "Attachment", "NotificationVersion", "NotificationReply", "NotificationVariant", "NotificationGuardian"}
+
+/*///////////////////////////////////////////////////////////////
+                          Expiry Cleanup
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Scheduler] Drop expired notifications from digests still being collected, and purge notifications ExpiredNotificationRetention after they expired.
+func purgeExpiredNotifications(db *sql.DB, now time.Time) error {
+	_, err := db.Exec(`DELETE DigestEntry FROM DigestEntry JOIN NotificationMessage AS Message ON Message.id = DigestEntry.notification_id
+	WHERE DigestEntry.digest_id IS NULL AND Message.expires_at <= ?`, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT id FROM NotificationMessage WHERE expires_at <= ?", now.Add(-config.ExpiredNotificationRetention).UTC())
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := purgeNotification(db, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("Purged %d expired notifications", len(ids))
+	}
+	return nil
+}
+
+// @Desc: [Expiry] Delete a notification along with everything recorded against it. Attachment content is left in the blob store, where other notifications may share it.
+func purgeNotification(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range notificationTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE notification_id = ?", id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM NotificationMessage WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return currentSearchIndex().Remove(db, id)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Expiry] Whether the notification has expired by `now`. Notifications without an expiry never do.
+func notificationExpired(notification model.ScheduledNotification, now time.Time) bool {
+	return notification.ExpiresAt != nil && !notification.ExpiresAt.After(now)
+}
+
+// @Desc: [Expiry] Whether a notification sent at `sendAt` may expire at `expiresAt`, which must come after it when set.
+func validExpiry(expiresAt *time.Time, sendAt time.Time) bool {
+	return expiresAt == nil || expiresAt.After(sendAt)
+}
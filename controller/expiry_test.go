@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Expiry
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Notifications should expire at their expiry time, and never without one.
+func TestNotificationExpired(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+	notification := model.ScheduledNotification{Notification: "Zoo trip tomorrow", ExpiresAt: &expiresAt}
+
+	assert.False(t, notificationExpired(notification, now), "A notification should not expire before its expiry time.")
+	assert.True(t, notificationExpired(notification, expiresAt), "A notification should expire at its expiry time.")
+	assert.False(t, notificationExpired(model.ScheduledNotification{}, now.Add(1000*time.Hour)), "A notification without an expiry should never expire.")
+	log.Println("SUCCESS: TestNotificationExpired")
+}
+
+// @Desc: [FAIL] A notification should not expire before it is sent.
+func TestValidExpiry(t *testing.T) {
+	sendAt := time.Date(2026, 10, 22, 8, 0, 0, 0, time.UTC)
+	before, after := sendAt.Add(-time.Minute), sendAt.Add(time.Minute)
+
+	assert.True(t, validExpiry(nil, sendAt), "Notifications need not expire.")
+	assert.True(t, validExpiry(&after, sendAt))
+	assert.False(t, validExpiry(&sendAt, sendAt), "A notification should not expire as it is sent.")
+	assert.False(t, validExpiry(&before, sendAt), "A notification should not expire before it is sent.")
+	log.Println("SUCCESS: TestValidExpiry")
+}
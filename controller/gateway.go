@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+)
+
+const (
+	// Time allowed to write a message before the client is considered gone
+	gatewayWriteWait = 10 * time.Second
+	// Time allowed between pongs before the client is considered gone
+	gatewayPongWait = 60 * time.Second
+	// Pings are sent well within gatewayPongWait so that a healthy client always answers in time
+	gatewayPingInterval = gatewayPongWait * 9 / 10
+	// Displays only ever send control frames, anything larger is a misbehaving client
+	gatewayMaxMessageSize = 512
+)
+
+// Classroom displays are authenticated by their token rather than by origin
+var gatewayUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// A notification sent to a WebSocket client
+type gatewayMessage struct {
+	Id   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+/*///////////////////////////////////////////////////////////////
+                     Live Classroom Gateway Endpoint
+//////////////////////////////////////////////////////////////*/
+
+// ClassroomGateway: Upgrade to a WebSocket that receives every notification of a teacher as soon as it is sent
+// URL : /teachers/{teacher}/live
+// Parameters: teacher, token (query or Authorization: Bearer header), last_event_id
+// Method: GET
+// Output: WebSocket of JSON Encoded "notification" messages, else error message.
+func ClassroomGateway(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+	if !validEmailFormat(teacher) {
+		ErrorResponse("Invalid teacher email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if !validGatewayToken(teacher, gatewayRequestToken(r)) {
+		ErrorResponse("Invalid gateway token.", w, http.StatusUnauthorized)
+		return
+	}
+
+	lastEventId, err := parseLastEventId(r)
+	if err != nil {
+		ErrorResponse("Invalid Last-Event-ID.", w, http.StatusBadRequest)
+		return
+	}
+
+	conn, err := gatewayUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		return
+	}
+	defer conn.Close()
+
+	subscription, missed := notificationHub.Subscribe(classroomTopic(teacher), lastEventId)
+	defer subscription.Close()
+
+	go readGatewayPump(conn, subscription)
+	writeGatewayPump(conn, subscription, missed)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Gateway] Send missed and new notifications along with periodic pings, until the client goes away or is dropped by the hub for falling behind.
+func writeGatewayPump(conn *websocket.Conn, subscription *hubSubscription, missed []hubEvent) {
+	ping := time.NewTicker(gatewayPingInterval)
+	defer ping.Stop()
+
+	for _, event := range missed {
+		if err := writeGatewayEvent(conn, event); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case event, open := <-subscription.Events:
+			if !open {
+				// Either the client went away or it could not keep up, in which case it reconnects with its last event id
+				conn.SetWriteDeadline(time.Now().Add(gatewayWriteWait))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow, reconnect to catch up"))
+				return
+			}
+			if err := writeGatewayEvent(conn, event); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(gatewayWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// @Desc: [Gateway] Read until the connection fails, answering to pongs, and end the subscription so that the write pump stops too.
+func readGatewayPump(conn *websocket.Conn, subscription *hubSubscription) {
+	defer subscription.Close()
+
+	conn.SetReadLimit(gatewayMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(gatewayPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(gatewayPongWait))
+	})
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// @Desc: [Gateway] Write a hub event as a JSON text message.
+func writeGatewayEvent(conn *websocket.Conn, event hubEvent) error {
+	conn.SetWriteDeadline(time.Now().Add(gatewayWriteWait))
+	err := conn.WriteJSON(gatewayMessage{Id: event.Id, Type: event.Type, Data: event.Data})
+	if err != nil {
+		log.Printf("Failed to write to classroom gateway %s: %v", event.Topic, err)
+	}
+	return err
+}
+
+// @Desc: [Gateway] Token given on connect, from the `token` query parameter (browsers cannot set headers on WebSockets) or an Authorization: Bearer header.
+func gatewayRequestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); len(token) > 0 {
+		return token
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// @Desc: Token that lets a classroom display connect to the teacher's live gateway, handed out by the school's administrators.
+func GatewayToken(teacher string) string {
+	mac := hmac.New(sha256.New, []byte(config.GatewaySecret))
+	mac.Write([]byte(teacher))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// @Desc: [Gateway] Whether the token was issued for the teacher.
+func validGatewayToken(teacher string, token string) bool {
+	return len(token) > 0 && hmac.Equal([]byte(token), []byte(GatewayToken(teacher)))
+}
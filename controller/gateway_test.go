@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Live Classroom Gateway
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: Serve the classroom gateway the way main.go routes it.
+func newGatewayServer() *httptest.Server {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/teachers/{teacher}/live", ClassroomGateway).Methods("GET")
+	return httptest.NewServer(router)
+}
+
+// @Desc: [VALID] An authenticated client should be replayed missed notifications and receive new ones as they are published.
+func TestClassroomGatewayReceivesNotifications(t *testing.T) {
+	server := newGatewayServer()
+	defer server.Close()
+
+	teacher := "gateway@gmail.com"
+	notificationHub.Publish(classroomTopic(teacher), streamEventNotification, map[string]string{"notification": "before connecting"})
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/teachers/" + teacher + "/live?token=" + GatewayToken(teacher)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var replayed gatewayMessage
+	assert.NoError(t, conn.ReadJSON(&replayed))
+	assert.Equal(t, streamEventNotification, replayed.Type, "Message should be a notification.")
+	assert.JSONEq(t, `{"notification":"before connecting"}`, string(replayed.Data), "Notification before connecting should be replayed.")
+
+	// The subscription is registered before the replay is written, so this is delivered live
+	notificationHub.Publish(classroomTopic(teacher), streamEventNotification, map[string]string{"notification": "live"})
+
+	var live gatewayMessage
+	assert.NoError(t, conn.ReadJSON(&live))
+	assert.Greater(t, live.Id, replayed.Id, "Event ids should increase.")
+	assert.JSONEq(t, `{"notification":"live"}`, string(live.Data), "New notification should be received.")
+	log.Println("SUCCESS: TestClassroomGatewayReceivesNotifications")
+}
+
+// @Desc: [FAIL] Connecting with a token issued for another teacher, which should fail with HTTP code 401.
+func TestClassroomGatewayInvalidToken(t *testing.T) {
+	server := newGatewayServer()
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/teachers/t1@gmail.com/live"
+	header := http.Header{"Authorization": []string{"Bearer " + GatewayToken("t2@gmail.com")}}
+	_, response, err := websocket.DefaultDialer.Dial(url, header)
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode, "Status code should be 401")
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+	assert.Equal(t, "Invalid gateway token.", body["message"], "Response should be the same as expected.")
+	log.Println("SUCCESS: TestClassroomGatewayInvalidToken")
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+var classNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,45}$`)
+
+// Why a group member was left out of a notification's recipients
+const (
+	droppedNotRegistered = "not_registered"
+	droppedSuspended     = "suspended"
+	droppedOptedOut      = "opted_out"
+	droppedInactive      = "inactive"
+)
+
+/*///////////////////////////////////////////////////////////////
+                          Class Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// RegisterClassStudents: Add one or more students to a named class
+// URL : /classes/register
+// Parameters: class, students
+// Method: POST
+// Output: No content if successful, else error message.
+func RegisterClassStudents(w http.ResponseWriter, r *http.Request) {
+	var classRegistration model.ClassRegistration
+
+	err := json.NewDecoder(r.Body).Decode(&classRegistration)
+	if err != nil {
+		ErrorResponse("Failed request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if !isValidClassName(classRegistration.Class) {
+		ErrorResponse("Invalid class specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	for _, student := range classRegistration.Students {
+		if !validEmailFormat(student) {
+			ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if err := ensureProfiles(db, studentProfiles, classRegistration.Students...); err != nil {
+		ErrorResponse("Failed to register students to class.", w, http.StatusNotFound)
+		return
+	}
+
+	// Students already in the class are left as they are
+	for _, student := range classRegistration.Students {
+		_, err = db.Exec(`INSERT INTO ClassMember(class, student) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM ClassMember WHERE class = ? AND student = ?)`,
+			classRegistration.Class, student, classRegistration.Class, student)
+		if err != nil {
+			ErrorResponse("Failed to register students to class.", w, http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClassStudents: List the students of a named class
+// URL : /classes/{class}
+// Parameters: class
+// Method: GET
+// Output: JSON Encoded Array of student emails, else error message.
+func ClassStudents(w http.ResponseWriter, r *http.Request) {
+	class := mux.Vars(r)["class"]
+	if !isValidClassName(class) {
+		ErrorResponse("Invalid class specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	students, err := queryColumn(db, "SELECT student FROM ClassMember WHERE class = ? ORDER BY student", class)
+	if err != nil {
+		ErrorResponse("Failed to get class students.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(students)
+}
+
+/*///////////////////////////////////////////////////////////////
+                      Group Mention Expansion
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [RetrieveForNotification] Expand a group mention of the sending teacher into the student emails it stands for.
+func expandGroupMention(db *sql.DB, teacher string, group string) ([]string, error) {
+	kind, target, _ := strings.Cut(group, ":")
+	switch kind {
+	case groupMentionAll:
+		return queryColumn(db, "SELECT student FROM Teach WHERE teacher = ? ORDER BY student", teacher)
+	case groupMentionClass:
+		return queryColumn(db, "SELECT student FROM ClassMember WHERE class = ? ORDER BY student", target)
+	default:
+		// @common:<teacher> and @teacher:<teacher> are the students shared by both teachers
+		return queryColumn(db, `SELECT student FROM Teach WHERE teacher IN (?, ?) GROUP BY student
+		HAVING COUNT(DISTINCT teacher) = 2 ORDER BY student`, teacher, target)
+	}
+}
+
+// @Desc: [RetrieveForNotification] Expand every group mention for this notification only, returning the members of each group and why any member cannot receive it.
+// Members are not registered for the teacher's notifications, so a group mention does not subscribe them to later ones.
+func resolveGroupMentions(db *sql.DB, teacher string, groups []string, includeSuspended bool) (map[string][]string, map[string]string, error) {
+	members := make(map[string][]string)
+	excluded := make(map[string]string)
+	for _, group := range groups {
+		students, err := expandGroupMention(db, teacher, group)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, student := range students {
+			if _, checked := excluded[student]; checked {
+				continue
+			}
+			excluded[student], err = groupMemberExclusion(db, teacher, student, includeSuspended)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		members[group] = students
+	}
+	return members, excluded, nil
+}
+
+// @Desc: [RetrieveForNotification] Why a group member cannot receive the teacher's notification, applying the same rules as getStudentsForNotification. Empty if they can.
+func groupMemberExclusion(db *sql.DB, teacher string, student string, includeSuspended bool) (string, error) {
+	var reason string
+	err := db.QueryRow(`SELECT CASE
+		WHEN NOT EXISTS (SELECT 1 FROM Teach WHERE teacher = ? AND student = ?) THEN ?
+		WHEN NOT ? AND EXISTS (SELECT 1 FROM Suspend WHERE student = ?) THEN ?
+		WHEN EXISTS (SELECT 1 FROM StudentPreference WHERE student = ? AND opted_out = 1)
+			OR EXISTS (SELECT 1 FROM TeacherOptOut WHERE teacher = ? AND student = ?) THEN ?
+		WHEN EXISTS (SELECT 1 FROM Student WHERE email = ? AND status <> 'active') THEN ?
+		ELSE '' END`,
+		teacher, student, droppedNotRegistered,
+		includeSuspended, student, droppedSuspended,
+		student, teacher, student, droppedOptedOut,
+		student, droppedInactive).Scan(&reason)
+	return reason, err
+}
+
+// @Desc: [RetrieveForNotification] Add the group members that can receive the notification to its recipients, once each.
+func addGroupRecipients(recipients []string, groups []string, members map[string][]string, excluded map[string]string) []string {
+	isRecipient := make(map[string]bool)
+	for _, student := range recipients {
+		isRecipient[student] = true
+	}
+	for _, group := range groups {
+		for _, student := range members[group] {
+			if !isRecipient[student] && len(excluded[student]) == 0 {
+				isRecipient[student] = true
+				recipients = append(recipients, student)
+			}
+		}
+	}
+	return recipients
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Classes] Class names such as 3A or sec-4_express are kept to letters, digits, '-' and '_'.
+func isValidClassName(class string) bool {
+	return classNamePattern.MatchString(class)
+}
+
+// @Desc: Run a query selecting a single string column, e.g. student emails, always returning a non-nil slice.
+func queryColumn(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := make([]string, 0)
+	for rows.Next() {
+		var student string
+		if err := rows.Scan(&student); err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
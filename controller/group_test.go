@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Classes & Group Mentions
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Registering students to a class name containing spaces, which should fail with HTTP code 400.
+func TestRegisterClassStudentsInvalidClass(t *testing.T) {
+	var jsonBody = []byte(`{"class": "3 A","students":["s1@gmail.com"]}`)
+	req, err := http.NewRequest("POST", "/api/classes/register", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RegisterClassStudents)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid class specified."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRegisterClassStudentsInvalidClass")
+}
+
+// @Desc: [FAIL] Registering a malformed student email to a class, which should fail with HTTP code 400.
+func TestRegisterClassStudentsInvalidStudent(t *testing.T) {
+	var jsonBody = []byte(`{"class": "3A","students":["s1@gmail.com","notanemail"]}`)
+	req, err := http.NewRequest("POST", "/api/classes/register", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RegisterClassStudents)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid student email format."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRegisterClassStudentsInvalidStudent")
+}
+
+// @Desc: [FAIL] Listing the students of an invalid class name, which should fail with HTTP code 400.
+func TestClassStudentsInvalidClass(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/classes/3A%3B", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"class": "3A;"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ClassStudents)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid class specified."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestClassStudentsInvalidClass")
+}
+
+// @Desc: [VALID] The breakdown should list the group members that actually received the notification, and why the others did not.
+func TestGroupBreakdown(t *testing.T) {
+	members := map[string][]string{
+		"class:3A": {"s1@gmail.com", "s2@gmail.com"},
+		"all":      {"s1@gmail.com", "s2@gmail.com", "s3@gmail.com"},
+	}
+	excluded := map[string]string{"s1@gmail.com": droppedSuspended}
+	breakdown := groupBreakdown([]string{"class:3A", "all"}, members, []string{"s2@gmail.com", "s3@gmail.com"}, excluded)
+
+	assert.Equal(t, "@class:3A", breakdown[0].Mention, "Mention should keep its '@'.")
+	assert.Equal(t, []string{"s2@gmail.com"}, breakdown[0].Students, "Only recipients should be listed.")
+	assert.Equal(t, []string{"s2@gmail.com", "s3@gmail.com"}, breakdown[1].Students, "Only recipients should be listed.")
+	assert.Equal(t, []model.DroppedRecipient{{Student: "s1@gmail.com", Reason: droppedSuspended}}, breakdown[0].Dropped, "Members left out should be listed with why.")
+	log.Println("SUCCESS: TestGroupBreakdown")
+}
+
+// @Desc: [VALID] Group members should be added to the recipients once each, unless they were left out.
+func TestAddGroupRecipients(t *testing.T) {
+	members := map[string][]string{
+		"class:3A": {"s1@gmail.com", "s2@gmail.com", "s3@gmail.com"},
+		"all":      {"s2@gmail.com", "s4@gmail.com"},
+	}
+	excluded := map[string]string{"s1@gmail.com": droppedNotRegistered, "s2@gmail.com": "", "s3@gmail.com": "", "s4@gmail.com": ""}
+	recipients := addGroupRecipients([]string{"s3@gmail.com"}, []string{"class:3A", "all"}, members, excluded)
+
+	assert.Equal(t, []string{"s3@gmail.com", "s2@gmail.com", "s4@gmail.com"}, recipients, "Members not registered under the teacher should be left out.")
+	log.Println("SUCCESS: TestAddGroupRecipients")
+}
@@ -0,0 +1,611 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Who a notification reaches: the resolved students, their guardians instead, or both
+const (
+	audienceStudents  = "students"
+	audienceGuardians = "guardians"
+	audienceBoth      = "both"
+)
+
+const maxGuardianNameLength = 100
+
+// Relationships a guardian can have to a student, "guardian" unless given
+var guardianRelationships = map[string]bool{
+	"mother":      true,
+	"father":      true,
+	"parent":      true,
+	"grandparent": true,
+	"sibling":     true,
+	"guardian":    true,
+	"other":       true,
+}
+
+// A notification on its way to the guardians of one student, with the text that student was sent
+type guardianMessage struct {
+	Student string
+	// The student's text when it differs from the stored notification
+	Message     sql.NullString
+	Text        string
+	HTML        string
+	Attachments []model.Attachment
+	// Held back until the student is pushed the notification, directly or in their digest
+	Held bool
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Guardian Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// CreateGuardian: Save a guardian, who can be reached by email, phone or both
+// URL : /guardians
+// Parameters: name, email, phone
+// Method: POST
+// Output: JSON Encoded Object of the saved guardian, else error message.
+func CreateGuardian(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.GuardianBody
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	requestBody, err = validateGuardian(requestBody)
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if exists, err := guardianEmailExists(db, requestBody.Email, 0); err != nil || exists {
+		guardianConflictResponse(err, w)
+		return
+	}
+
+	guardian := model.Guardian{Name: requestBody.Name, Email: requestBody.Email, Phone: requestBody.Phone, CreatedAt: time.Now().UTC()}
+	result, err := db.Exec("INSERT INTO Guardian(name, email, phone, created_at) VALUES(?, ?, ?, ?)",
+		guardian.Name, nullableString(guardian.Email), nullableString(guardian.Phone), guardian.CreatedAt)
+	if err != nil {
+		ErrorResponse("Failed to save guardian.", w, http.StatusNotFound)
+		return
+	}
+	guardian.Id, err = result.LastInsertId()
+	if err != nil {
+		ErrorResponse("Failed to save guardian.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(guardian)
+}
+
+// GetGuardian: Retrieve a guardian along with the students they are linked to
+// URL : /guardians/{id}
+// Parameters: id
+// Method: GET
+// Output: JSON Encoded Object of the guardian, else error message.
+func GetGuardian(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid guardian id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	guardian, err := getGuardian(db, id)
+	if err != nil {
+		guardianNotFoundResponse(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(guardian)
+}
+
+// UpdateGuardian: Replace the name & contact details of a guardian
+// URL : /guardians/{id}
+// Parameters: name, email, phone
+// Method: PUT
+// Output: JSON Encoded Object of the updated guardian, else error message.
+func UpdateGuardian(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.GuardianBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid guardian id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	requestBody, err = validateGuardian(requestBody)
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	guardian, err := getGuardian(db, id)
+	if err != nil {
+		guardianNotFoundResponse(err, w)
+		return
+	}
+	if exists, err := guardianEmailExists(db, requestBody.Email, id); err != nil || exists {
+		guardianConflictResponse(err, w)
+		return
+	}
+
+	guardian.Name, guardian.Email, guardian.Phone = requestBody.Name, requestBody.Email, requestBody.Phone
+	_, err = db.Exec("UPDATE Guardian SET name = ?, email = ?, phone = ? WHERE id = ?", guardian.Name, nullableString(guardian.Email), nullableString(guardian.Phone), id)
+	if err != nil {
+		ErrorResponse("Failed to save guardian.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(guardian)
+}
+
+// DeleteGuardian: Delete a guardian, unlinking them from their students
+// URL : /guardians/{id}
+// Parameters: id
+// Method: DELETE
+// Output: No content if successful, else error message.
+func DeleteGuardian(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid guardian id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		ErrorResponse("Failed to delete guardian.", w, http.StatusNotFound)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM Guardian WHERE id = ?", id)
+	if err != nil {
+		ErrorResponse("Failed to delete guardian.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Guardian not found.", w, http.StatusNotFound)
+		return
+	}
+	if _, err = tx.Exec("DELETE FROM StudentGuardian WHERE guardian_id = ?", id); err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		ErrorResponse("Failed to delete guardian.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                      Student Guardian Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentGuardians: List the guardians of a student
+// URL : /students/{student}/guardians
+// Parameters: student
+// Method: GET
+// Output: JSON Encoded Array of the student's guardians, else error message.
+func StudentGuardians(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	guardians, err := getStudentGuardians(db, student)
+	if err != nil {
+		ErrorResponse("Failed to retrieve guardians.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(guardians)
+}
+
+// LinkStudentGuardian: Link a guardian to a student, or change how they are related
+// URL : /students/{student}/guardians/{id}
+// Parameters: relationship
+// Method: PUT
+// Output: JSON Encoded Object of the student's guardian, else error message.
+func LinkStudentGuardian(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.GuardianLinkBody
+
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid guardian id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	relationship := strings.ToLower(strings.TrimSpace(requestBody.Relationship))
+	if len(relationship) == 0 {
+		relationship = "guardian"
+	}
+	if !guardianRelationships[relationship] {
+		ErrorResponse("Invalid relationship, expected mother, father, parent, grandparent, sibling, guardian or other.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	guardian, err := getGuardian(db, id)
+	if err != nil {
+		guardianNotFoundResponse(err, w)
+		return
+	}
+
+	_, err = db.Exec("INSERT INTO StudentGuardian(student, guardian_id, relationship) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE relationship = VALUES(relationship)",
+		student, id, relationship)
+	if err != nil {
+		ErrorResponse("Failed to link guardian.", w, http.StatusNotFound)
+		return
+	}
+	guardian.Students = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(model.StudentGuardian{Guardian: guardian, Relationship: relationship})
+}
+
+// UnlinkStudentGuardian: Unlink a guardian from a student, keeping the guardian
+// URL : /students/{student}/guardians/{id}
+// Parameters: student, id
+// Method: DELETE
+// Output: No content if successful, else error message.
+func UnlinkStudentGuardian(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid guardian id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM StudentGuardian WHERE student = ? AND guardian_id = ?", student, id)
+	if err != nil {
+		ErrorResponse("Failed to unlink guardian.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Guardian not found.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Guardian Delivery
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Notifications] Send each student's text of a notification to their guardians. A guardian of several recipients gets one message
+// per distinct text, naming the students it is about, and every message is recorded against the notification.
+// Messages held back for their student are left for sendHeldGuardianPushes.
+func deliverToGuardians(db *sql.DB, notification model.ScheduledNotification, messages []guardianMessage) ([]model.GuardianRecipient, error) {
+	type guardianKey struct {
+		guardian int64
+		text     string
+	}
+	var order []guardianKey
+	pending := make(map[guardianKey]*channelMessage)
+	var recipients []model.GuardianRecipient
+	recipientIndex := make(map[int64]int)
+
+	// Every guardian is recorded before any is sent to, so that a retried delivery only sends to those not recorded yet
+	tx, err := db.Begin()
+	if err != nil {
+		return recipients, err
+	}
+	defer tx.Rollback()
+
+	for _, message := range messages {
+		guardians, err := getStudentGuardians(db, message.Student)
+		if err != nil {
+			return recipients, err
+		}
+		for _, linked := range guardians {
+			guardian := linked.Guardian
+			recorded, err := tx.Exec("INSERT IGNORE INTO NotificationGuardian(notification_id, guardian_id, student, message, push_held) VALUES(?, ?, ?, ?, ?)",
+				notification.Id, guardian.Id, message.Student, message.Message, message.Held)
+			if err != nil {
+				return recipients, err
+			}
+			if affected, _ := recorded.RowsAffected(); affected == 0 {
+				continue
+			}
+
+			if !message.Held {
+				key := guardianKey{guardian.Id, message.Text}
+				if push, ok := pending[key]; ok {
+					push.Wards = append(push.Wards, message.Student)
+				} else {
+					order = append(order, key)
+					pending[key] = &channelMessage{NotificationId: notification.Id, Teacher: notification.Teacher, Guardian: &guardian, Wards: []string{message.Student},
+						Text: message.Text, HTML: message.HTML, Attachments: message.Attachments}
+				}
+			}
+
+			if i, ok := recipientIndex[guardian.Id]; ok {
+				recipients[i].Students = append(recipients[i].Students, message.Student)
+			} else {
+				recipientIndex[guardian.Id] = len(recipients)
+				recipients = append(recipients, model.GuardianRecipient{Id: guardian.Id, Name: guardian.Name, Students: []string{message.Student}})
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return recipients, err
+	}
+
+	// Guardians have no preferences of their own, so they are reached on every channel they have contact details for
+	for _, key := range order {
+		pushNotification(db, *pending[key], model.StudentPreferences{})
+	}
+	return recipients, nil
+}
+
+// @Desc: [Scheduler] Push the messages held back for guardians once their student was pushed the notification, or for a notification only for guardians,
+// once their student's quiet hours are over. Messages of recalled or expired notifications are never sent.
+func sendHeldGuardianPushes(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`SELECT Message.id, Message.teacher, COALESCE(Held.message, Message.message), Message.format, Held.guardian_id, Held.student
+	FROM NotificationGuardian AS Held JOIN NotificationMessage AS Message ON Message.id = Held.notification_id
+	WHERE Held.push_held = 1 AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?)
+	AND NOT EXISTS (SELECT 1 FROM DigestEntry WHERE notification_id = Held.notification_id AND student = Held.student AND digest_id IS NULL)
+	AND NOT EXISTS (SELECT 1 FROM NotificationRecipient WHERE notification_id = Held.notification_id AND student = Held.student AND push_held = 1)
+	ORDER BY Held.notification_id, Held.guardian_id, Held.student`, notificationStatusSent, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	type heldMessage struct {
+		message  channelMessage
+		guardian int64
+		format   string
+	}
+	var held []heldMessage
+	for rows.Next() {
+		var h heldMessage
+		if err := rows.Scan(&h.message.NotificationId, &h.message.Teacher, &h.message.Text, &h.format, &h.guardian, &h.message.Student); err != nil {
+			rows.Close()
+			return err
+		}
+		held = append(held, h)
+	}
+	rows.Close()
+
+	type guardianKey struct {
+		notification int64
+		guardian     int64
+		text         string
+	}
+	var order []guardianKey
+	pending := make(map[guardianKey]*channelMessage)
+	for _, h := range held {
+		preferences, err := getStudentPreferences(db, h.message.Student)
+		if err != nil {
+			return err
+		}
+		// Picked up again on a later tick once quiet hours are over
+		if inQuietHours(preferences, now) {
+			continue
+		}
+
+		// Claim the message first so that another scheduler cannot send it twice
+		result, err := db.Exec("UPDATE NotificationGuardian SET push_held = 0 WHERE notification_id = ? AND guardian_id = ? AND student = ? AND push_held = 1",
+			h.message.NotificationId, h.guardian, h.message.Student)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+
+		key := guardianKey{h.message.NotificationId, h.guardian, h.message.Text}
+		if push, ok := pending[key]; ok {
+			push.Wards = append(push.Wards, h.message.Student)
+			continue
+		}
+		guardian, err := getGuardian(db, h.guardian)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		attachments, err := getAttachments(db, h.message.NotificationId)
+		if err != nil {
+			return err
+		}
+		text, html := renderNotificationBody(h.message.Text, h.format)
+		order = append(order, key)
+		pending[key] = &channelMessage{NotificationId: h.message.NotificationId, Teacher: h.message.Teacher, Guardian: &guardian, Wards: []string{h.message.Student},
+			Text: text, HTML: html, Attachments: signAttachments(attachments, h.message.Student)}
+	}
+
+	for _, key := range order {
+		pushNotification(db, *pending[key], model.StudentPreferences{})
+	}
+	return nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Guardian] Trim & normalise the guardian's details, requiring a name and a valid email or phone number to reach them on.
+func validateGuardian(guardian model.GuardianBody) (model.GuardianBody, error) {
+	guardian.Name = strings.TrimSpace(guardian.Name)
+	guardian.Email = strings.TrimSpace(guardian.Email)
+	guardian.Phone = normalisePhone(guardian.Phone)
+	if len(guardian.Name) == 0 || len(guardian.Name) > maxGuardianNameLength {
+		return guardian, errors.New("Invalid guardian name.")
+	}
+	if len(guardian.Email) == 0 && len(guardian.Phone) == 0 {
+		return guardian, errors.New("Specify an email or phone number for the guardian.")
+	}
+	if len(guardian.Email) > 0 && !validEmailFormat(guardian.Email) {
+		return guardian, errors.New("Invalid guardian email format.")
+	}
+	if len(guardian.Phone) > 0 && !validPhoneFormat(guardian.Phone) {
+		return guardian, errors.New("Invalid phone number, expected E.164 e.g. +6591234567.")
+	}
+	return guardian, nil
+}
+
+// @Desc: [Guardian] Whether a guardian other than `exceptId` already has the email address.
+func guardianEmailExists(db *sql.DB, email string, exceptId int64) (bool, error) {
+	if len(email) == 0 {
+		return false, nil
+	}
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM Guardian WHERE email = ? AND id <> ?", email, exceptId).Scan(&count)
+	return count > 0, err
+}
+
+// @Desc: [Guardian] A guardian along with the students they are linked to.
+func getGuardian(db *sql.DB, id int64) (model.Guardian, error) {
+	guardian, err := scanGuardian(db.QueryRow("SELECT id, name, email, phone, created_at FROM Guardian WHERE id = ?", id))
+	if err != nil {
+		return guardian, err
+	}
+
+	rows, err := db.Query("SELECT student, relationship FROM StudentGuardian WHERE guardian_id = ? ORDER BY student", id)
+	if err != nil {
+		return guardian, err
+	}
+	defer rows.Close()
+
+	guardian.Students = make([]model.GuardianLink, 0)
+	for rows.Next() {
+		var link model.GuardianLink
+		if err := rows.Scan(&link.Student, &link.Relationship); err != nil {
+			return guardian, err
+		}
+		guardian.Students = append(guardian.Students, link)
+	}
+	return guardian, rows.Err()
+}
+
+// @Desc: [Guardian] The guardians of a student, in the order they were added.
+func getStudentGuardians(db *sql.DB, student string) ([]model.StudentGuardian, error) {
+	rows, err := db.Query(`SELECT Guardian.id, Guardian.name, Guardian.email, Guardian.phone, Guardian.created_at, StudentGuardian.relationship
+	FROM StudentGuardian JOIN Guardian ON Guardian.id = StudentGuardian.guardian_id
+	WHERE StudentGuardian.student = ? ORDER BY Guardian.id`, student)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guardians := make([]model.StudentGuardian, 0)
+	for rows.Next() {
+		var guardian model.StudentGuardian
+		var email, phone sql.NullString
+		if err := rows.Scan(&guardian.Id, &guardian.Name, &email, &phone, &guardian.CreatedAt, &guardian.Relationship); err != nil {
+			return nil, err
+		}
+		guardian.Email, guardian.Phone = email.String, phone.String
+		guardians = append(guardians, guardian)
+	}
+	return guardians, rows.Err()
+}
+
+// @Desc: [Guardian] Scans a Guardian row.
+func scanGuardian(row rowScanner) (model.Guardian, error) {
+	var guardian model.Guardian
+	var email, phone sql.NullString
+	err := row.Scan(&guardian.Id, &guardian.Name, &email, &phone, &guardian.CreatedAt)
+	guardian.Email, guardian.Phone = email.String, phone.String
+	return guardian, err
+}
+
+// @Desc: [Guardian] Whether the audience is one a notification can be sent to.
+func isValidAudience(audience string) bool {
+	return audience == audienceStudents || audience == audienceGuardians || audience == audienceBoth
+}
+
+// @Desc: [Guardian] NULL for an empty string, so that guardians without an email do not collide on the unique email index.
+func nullableString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: len(value) > 0}
+}
+
+// @Desc: [Guardian] Respond to a guardian lookup failing, telling a missing guardian apart from a database failure.
+func guardianNotFoundResponse(err error, w http.ResponseWriter) {
+	if err == sql.ErrNoRows {
+		ErrorResponse("Guardian not found.", w, http.StatusNotFound)
+		return
+	}
+	ErrorResponse("Failed to retrieve guardian.", w, http.StatusNotFound)
+}
+
+// @Desc: [Guardian] Respond to a guardian's email being taken, or the check for it failing.
+func guardianConflictResponse(err error, w http.ResponseWriter) {
+	if err != nil {
+		ErrorResponse("Failed to save guardian.", w, http.StatusNotFound)
+		return
+	}
+	ErrorResponse("A guardian with this email already exists.", w, http.StatusConflict)
+}
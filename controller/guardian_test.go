@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Guardians
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Guardians without a name, without any contact details or with malformed ones should be rejected.
+func TestValidateGuardianInvalid(t *testing.T) {
+	cases := []struct {
+		guardian model.GuardianBody
+		expected string
+	}{
+		{model.GuardianBody{Name: "  ", Email: "meiling@gmail.com"}, "Invalid guardian name."},
+		{model.GuardianBody{Name: "Tan Mei Ling"}, "Specify an email or phone number for the guardian."},
+		{model.GuardianBody{Name: "Tan Mei Ling", Email: "meiling"}, "Invalid guardian email format."},
+		{model.GuardianBody{Name: "Tan Mei Ling", Phone: "91234567"}, "Invalid phone number, expected E.164 e.g. +6591234567."},
+	}
+	for _, c := range cases {
+		_, err := validateGuardian(c.guardian)
+		assert.EqualError(t, err, c.expected)
+	}
+
+	guardian, err := validateGuardian(model.GuardianBody{Name: " Tan Mei Ling ", Phone: "+65 9123-4567"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.GuardianBody{Name: "Tan Mei Ling", Phone: "+6591234567"}, guardian, "Details should be trimmed & normalised.")
+	log.Println("SUCCESS: TestValidateGuardianInvalid")
+}
+
+// @Desc: [VALID] Guardians should be emailed & texted at their own contact details, naming the students the notification is about.
+func TestGuardianChannelMessage(t *testing.T) {
+	message := channelMessage{NotificationId: 12, Teacher: "t1@gmail.com", Guardian: &model.Guardian{Id: 3, Name: "Tan Mei Ling", Email: "meiling@gmail.com"},
+		Wards: []string{"s1@gmail.com", "s2@gmail.com"}, Text: "Zoo trip on Friday"}
+
+	built, err := buildEmail("school@gmail.com", message)
+	assert.NoError(t, err)
+	email := string(built)
+	assert.Contains(t, email, "To: meiling@gmail.com\r\n")
+	assert.Contains(t, email, "Subject: New notification from t1@gmail.com about s1@gmail.com, s2@gmail.com\r\n")
+	assert.Contains(t, email, "You are receiving this as a guardian of s1@gmail.com, s2@gmail.com.")
+	assert.False(t, strings.Contains(email, "unsubscribe"), "Guardians should not be sent a student's unsubscribe link.")
+
+	assert.Equal(t, "t1@gmail.com about s1@gmail.com, s2@gmail.com: Zoo trip on Friday", smsText(message))
+	assert.NoError(t, (&SMSChannel{}).Send(nil, message), "Guardians without a phone number should be skipped.")
+	log.Println("SUCCESS: TestGuardianChannelMessage")
+}
+
+// @Desc: [FAIL] Guardians should only be linked to students with a known relationship.
+func TestLinkStudentGuardianInvalidRelationship(t *testing.T) {
+	request, _ := http.NewRequest("PUT", "/api/students/s1@gmail.com/guardians/3", bytes.NewBufferString(`{"relationship": "neighbour"}`))
+	request = mux.SetURLVars(request, map[string]string{"student": "s1@gmail.com", "id": "3"})
+	response := httptest.NewRecorder()
+	LinkStudentGuardian(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"message": "Invalid relationship, expected mother, father, parent, grandparent, sibling, guardian or other."}`, response.Body.String())
+	log.Println("SUCCESS: TestLinkStudentGuardianInvalidRelationship")
+}
+
+// @Desc: [FAIL] Unlinking a guardian from a malformed student email, which should fail with HTTP code 400.
+func TestUnlinkStudentGuardianInvalidStudent(t *testing.T) {
+	request, _ := http.NewRequest("DELETE", "/api/students/s1/guardians/3", nil)
+	request = mux.SetURLVars(request, map[string]string{"student": "s1", "id": "3"})
+	response := httptest.NewRecorder()
+	UnlinkStudentGuardian(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"message": "Invalid student email format."}`, response.Body.String())
+	log.Println("SUCCESS: TestUnlinkStudentGuardianInvalidStudent")
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+const (
+	// Events kept per topic so that clients reconnecting with their last event id miss nothing
+	hubHistorySize = 100
+	// Events buffered per subscriber before it is considered too slow and dropped
+	hubSubscriberBuffer = 64
+)
+
+// An event published on a hub topic, with an id that increases across all topics
+type hubEvent struct {
+	Id    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+// Hub: In-process publish/subscribe of notification events, keyed by topic such as "student:<email>"
+type Hub struct {
+	mu          sync.Mutex
+	lastId      uint64
+	history     map[string][]hubEvent
+	subscribers map[string]map[*hubSubscription]bool
+}
+
+// A subscriber of one topic. Events is closed once the subscription ends, either by Close or by the hub dropping a subscriber that fell behind.
+type hubSubscription struct {
+	hub    *Hub
+	topic  string
+	Events chan hubEvent
+}
+
+// Hub fed by the notification pipeline and read by the streaming endpoints
+var notificationHub = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{history: make(map[string][]hubEvent), subscribers: make(map[string]map[*hubSubscription]bool)}
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Publish & Subscribe
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Hub] Publish a JSON encoded payload to every subscriber of the topic.
+// Subscribers whose buffer is full are dropped rather than blocking the notification pipeline.
+func (h *Hub) Publish(topic string, eventType string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode %s event for %s: %v", eventType, topic, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastId++
+	event := hubEvent{Id: h.lastId, Topic: topic, Type: eventType, Data: data}
+
+	history := append(h.history[topic], event)
+	if len(history) > hubHistorySize {
+		history = history[len(history)-hubHistorySize:]
+	}
+	h.history[topic] = history
+
+	for subscription := range h.subscribers[topic] {
+		select {
+		case subscription.Events <- event:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+// @Desc: [Hub] Subscribe to a topic, returning along with it the kept events published after `lastEventId`.
+func (h *Hub) Subscribe(topic string, lastEventId uint64) (*hubSubscription, []hubEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []hubEvent
+	for _, event := range h.history[topic] {
+		if event.Id > lastEventId {
+			missed = append(missed, event)
+		}
+	}
+
+	subscription := &hubSubscription{hub: h, topic: topic, Events: make(chan hubEvent, hubSubscriberBuffer)}
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[*hubSubscription]bool)
+	}
+	h.subscribers[topic][subscription] = true
+	return subscription, missed
+}
+
+// @Desc: [Hub] End the subscription. Safe to call more than once.
+func (s *hubSubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// @Desc: [Hub] Unregister a subscription & close its channel, with the hub lock held.
+func (h *Hub) remove(subscription *hubSubscription) {
+	subscribers := h.subscribers[subscription.topic]
+	if !subscribers[subscription] {
+		return
+	}
+	delete(subscribers, subscription)
+	if len(subscribers) == 0 {
+		delete(h.subscribers, subscription.topic)
+	}
+	close(subscription.Events)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Hub] Topic of the notifications received by a student.
+func studentTopic(student string) string {
+	return "student:" + student
+}
+
+// @Desc: [Hub] Topic of the notifications sent by a teacher, as shown on classroom displays.
+func classroomTopic(teacher string) string {
+	return "classroom:" + teacher
+}
+
+// @Desc: [Hub] Topic of the delivery updates of a teacher's notifications.
+func teacherTopic(teacher string) string {
+	return "teacher:" + teacher
+}
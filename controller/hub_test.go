@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Hub
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Subscribers should receive events of their own topic only, and late subscribers should be replayed the events after their last event id.
+func TestHubPublishAndReplay(t *testing.T) {
+	hub := NewHub()
+	subscription, missed := hub.Subscribe(studentTopic("s1@gmail.com"), 0)
+	defer subscription.Close()
+	assert.Empty(t, missed, "Nothing should be replayed on a new topic.")
+
+	hub.Publish(studentTopic("s1@gmail.com"), streamEventNotification, map[string]string{"notification": "first"})
+	hub.Publish(studentTopic("s2@gmail.com"), streamEventNotification, map[string]string{"notification": "other"})
+	hub.Publish(studentTopic("s1@gmail.com"), streamEventNotification, map[string]string{"notification": "second"})
+
+	first := <-subscription.Events
+	second := <-subscription.Events
+	assert.Equal(t, `{"notification":"first"}`, string(first.Data), "Events should arrive in order.")
+	assert.Equal(t, `{"notification":"second"}`, string(second.Data), "Events of other topics should not arrive.")
+	assert.Len(t, subscription.Events, 0, "No further events should be queued.")
+
+	late, missed := hub.Subscribe(studentTopic("s1@gmail.com"), first.Id)
+	defer late.Close()
+	assert.Len(t, missed, 1, "Only events after the last event id should be replayed.")
+	assert.Equal(t, second.Id, missed[0].Id, "The second event should be replayed.")
+	log.Println("SUCCESS: TestHubPublishAndReplay")
+}
+
+// @Desc: [VALID] A subscriber that stops reading should be dropped once its buffer is full instead of blocking publishers.
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	subscription, _ := hub.Subscribe(teacherTopic("t1@gmail.com"), 0)
+
+	for i := 0; i <= hubSubscriberBuffer; i++ {
+		hub.Publish(teacherTopic("t1@gmail.com"), streamEventDelivery, i)
+	}
+
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	assert.Equal(t, hubSubscriberBuffer, received, "The buffered events should be drained before the channel closes.")
+	subscription.Close()
+	log.Println("SUCCESS: TestHubDropsSlowSubscriber")
+}
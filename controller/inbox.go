@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const (
+	defaultInboxLimit = 20
+	maxInboxLimit     = 100
+)
+
+/*///////////////////////////////////////////////////////////////
+                         Student Inbox Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentInbox: List the notifications received by a student, newest first
+// URL : /students/{student}/inbox
+// Parameters: limit, offset, unread, archived
+// Method: GET
+// Output: JSON Encoded Object of one page of the student's notifications, else error message.
+func StudentInbox(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parseInboxParam(query.Get("limit"), defaultInboxLimit)
+	if err != nil || limit < 1 || limit > maxInboxLimit {
+		ErrorResponse("Invalid limit.", w, http.StatusBadRequest)
+		return
+	}
+	offset, err := parseInboxParam(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		ErrorResponse("Invalid offset.", w, http.StatusBadRequest)
+		return
+	}
+	unreadOnly, err := parseInboxFlag(query.Get("unread"))
+	if err != nil {
+		ErrorResponse("Invalid unread filter.", w, http.StatusBadRequest)
+		return
+	}
+	archived, err := parseInboxFlag(query.Get("archived"))
+	if err != nil {
+		ErrorResponse("Invalid archived filter.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	inbox, err := getInbox(db, student, archived, unreadOnly, limit, offset)
+	if err != nil {
+		ErrorResponse("Failed to get inbox.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(inbox)
+}
+
+// MarkInboxNotificationRead: Mark a notification in a student's inbox as read
+// URL : /students/{student}/inbox/{id}/read
+// Parameters: student, id
+// Method: POST
+// Output: No content if successful, else error message.
+func MarkInboxNotificationRead(w http.ResponseWriter, r *http.Request) {
+	updateInboxNotification(w, r, "read_at")
+}
+
+// ArchiveInboxNotification: Move a notification out of a student's inbox into their archive
+// URL : /students/{student}/inbox/{id}/archive
+// Parameters: student, id
+// Method: POST
+// Output: No content if successful, else error message.
+func ArchiveInboxNotification(w http.ResponseWriter, r *http.Request) {
+	updateInboxNotification(w, r, "archived_at")
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Inbox] Stamp the read_at or archived_at column of a student's notification, keeping the first time it was stamped.
+func updateInboxNotification(w http.ResponseWriter, r *http.Request, column string) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM NotificationRecipient WHERE notification_id = ? AND student = ?", id, student).Scan(&exists)
+	if err != nil {
+		ErrorResponse("Failed to update inbox.", w, http.StatusNotFound)
+		return
+	}
+	if exists == 0 {
+		ErrorResponse("Notification not found in inbox.", w, http.StatusNotFound)
+		return
+	}
+
+	// column is one of the fixed inbox columns, never user input
+	_, err = db.Exec("UPDATE NotificationRecipient SET "+column+" = COALESCE("+column+", ?) WHERE notification_id = ? AND student = ?",
+		time.Now().UTC(), id, student)
+	if err != nil {
+		ErrorResponse("Failed to update inbox.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Desc: [Inbox] One page of a student's inbox or archive, with the total matching & unread counts for paging and attachment links signed for the student.
+func getInbox(db *sql.DB, student string, archived bool, unreadOnly bool, limit int, offset int) (model.Inbox, error) {
+	inbox := model.Inbox{Student: student, Limit: limit, Offset: offset, Notifications: make([]model.InboxNotification, 0)}
+
+	// Only sent notifications are shown: recalled & expired ones are withdrawn from every inbox, and ones recorded by a failed delivery wait for it to be retried
+	filter := "Recipient.student = ? AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?) AND Recipient.archived_at IS NULL"
+	if archived {
+		filter = "Recipient.student = ? AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?) AND Recipient.archived_at IS NOT NULL"
+	}
+	now := time.Now().UTC()
+	err := db.QueryRow(`SELECT COUNT(*), COUNT(*) - COUNT(Recipient.read_at)
+	FROM NotificationRecipient AS Recipient JOIN NotificationMessage AS Message ON Message.id = Recipient.notification_id
+	WHERE `+filter, student, notificationStatusSent, now).
+		Scan(&inbox.Total, &inbox.Unread)
+	if err != nil {
+		return inbox, err
+	}
+	if unreadOnly {
+		filter += " AND Recipient.read_at IS NULL"
+		inbox.Total = inbox.Unread
+	}
+
+	rows, err := db.Query(`SELECT Message.id, Message.teacher, COALESCE(Recipient.message, Message.message), Message.format, Message.priority, Message.requires_ack,
+	Recipient.acknowledged_at, Message.sent_at, Recipient.read_at, Recipient.archived_at
+	FROM NotificationRecipient AS Recipient JOIN NotificationMessage AS Message ON Message.id = Recipient.notification_id
+	WHERE `+filter+` ORDER BY Message.sent_at DESC, Message.id DESC LIMIT ? OFFSET ?`, student, notificationStatusSent, now, limit, offset)
+	if err != nil {
+		return inbox, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notification model.InboxNotification
+		var format string
+		var acknowledgedAt, sentAt, readAt, archivedAt sql.NullTime
+		if err := rows.Scan(&notification.Id, &notification.Teacher, &notification.Notification, &format, &notification.Priority, &notification.RequiresAck,
+			&acknowledgedAt, &sentAt, &readAt, &archivedAt); err != nil {
+			return inbox, err
+		}
+		notification.Notification, notification.HTML = renderNotificationBody(notification.Notification, format)
+		if acknowledgedAt.Valid {
+			notification.AcknowledgedAt = &acknowledgedAt.Time
+		}
+		if sentAt.Valid {
+			notification.SentAt = &sentAt.Time
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		if archivedAt.Valid {
+			notification.ArchivedAt = &archivedAt.Time
+		}
+		mentions, _ := parseMentions(notification.Notification)
+		notification.Mentions = mentionSpans(notification.Notification, mentions)
+		inbox.Notifications = append(inbox.Notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return inbox, err
+	}
+	rows.Close()
+
+	for i, notification := range inbox.Notifications {
+		attachments, err := getAttachments(db, notification.Id)
+		if err != nil {
+			return inbox, err
+		}
+		inbox.Notifications[i].Attachments = signAttachments(attachments, student)
+	}
+	return inbox, nil
+}
+
+// @Desc: [Inbox] Parse an optional integer query parameter, falling back to `fallback` when absent.
+func parseInboxParam(value string, fallback int) (int, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// @Desc: [Inbox] Parse an optional boolean query parameter, absent meaning false.
+func parseInboxFlag(value string) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Student Inbox
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Listing the inbox of an invalid student email, which should fail with HTTP code 400.
+func TestStudentInboxInvalidStudent(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/students/s1/inbox", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(StudentInbox)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid student email format."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestStudentInboxInvalidStudent")
+}
+
+// @Desc: [FAIL] Listing the inbox with out of range paging or malformed filters, which should fail with HTTP code 400.
+func TestStudentInboxInvalidParams(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"limit=0", `{"message":"Invalid limit."}`},
+		{"limit=101", `{"message":"Invalid limit."}`},
+		{"offset=-1", `{"message":"Invalid offset."}`},
+		{"unread=maybe", `{"message":"Invalid unread filter."}`},
+		{"archived=2", `{"message":"Invalid archived filter."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", "/api/students/s1@gmail.com/inbox?"+c.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(StudentInbox)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400 for %s", c.query)
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestStudentInboxInvalidParams")
+}
+
+// @Desc: [FAIL] Marking a notification with a non-numeric id as read, which should fail with HTTP code 400.
+func TestMarkInboxNotificationReadInvalidId(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/students/s1@gmail.com/inbox/abc/read", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com", "id": "abc"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(MarkInboxNotificationRead)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid notification id."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestMarkInboxNotificationReadInvalidId")
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// A BCP 47 style language tag, e.g. en, zh or zh-SG. Tags are compared in lowercase.
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// A language variant the content filters found something in, recorded on its own once the notification is saved
+type variantModeration struct {
+	Original string
+	Result   model.ModerationResult
+}
+
+// @Desc: [Localisation] Lowercase form of a language tag, used for storing & comparing.
+func normaliseLanguage(language string) string {
+	return strings.ToLower(strings.TrimSpace(language))
+}
+
+// @Desc: [Localisation] Whether the (normalised) language tag is well formed.
+func isValidLanguage(language string) bool {
+	return languagePattern.MatchString(language)
+}
+
+// @Desc: [Localisation] The primary language of a tag, e.g. zh for zh-sg.
+func baseLanguage(language string) string {
+	base, _, _ := strings.Cut(language, "-")
+	return base
+}
+
+// @Desc: [Localisation] Normalise & check the language variants of a notification written in `language`. Templated variants must be valid templates too.
+func validateVariants(variants map[string]string, language string, templated bool) (map[string]string, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	validated := make(map[string]string, len(variants))
+	for variantLanguage, text := range variants {
+		variantLanguage = normaliseLanguage(variantLanguage)
+		if !isValidLanguage(variantLanguage) {
+			return nil, errors.New("Invalid variant language: " + variantLanguage + ".")
+		}
+		if _, duplicate := validated[variantLanguage]; duplicate || variantLanguage == language {
+			return nil, errors.New("Duplicate variant language: " + variantLanguage + ".")
+		}
+		if len(strings.TrimSpace(text)) == 0 {
+			return nil, errors.New("Empty variant: " + variantLanguage + ".")
+		}
+		if templated {
+			if _, err := parseTemplate(text); err != nil {
+				return nil, err
+			}
+		}
+		validated[variantLanguage] = text
+	}
+	return validated, nil
+}
+
+// @Desc: [Localisation] Run every variant through the content filters, returning the filtered variants along with what was found in each.
+// A variant that is blocked blocks the whole notification.
+func moderateVariants(variants map[string]string) (map[string]string, []variantModeration, bool) {
+	var found []variantModeration
+	blocked := false
+	for language, original := range variants {
+		text, result := moderateContent(original)
+		variants[language] = text
+		if result.Outcome != moderationAllow {
+			found = append(found, variantModeration{Original: original, Result: result})
+		}
+		if result.Outcome == moderationBlock {
+			blocked = true
+		}
+	}
+	return variants, found, blocked
+}
+
+// @Desc: [Localisation] The findings of the notification and each of its variants combined into one result for the teacher.
+func mergeModeration(result model.ModerationResult, variants []variantModeration) model.ModerationResult {
+	merged := model.ModerationResult{Outcome: result.Outcome, Findings: append([]model.ModerationFinding(nil), result.Findings...)}
+	for _, variant := range variants {
+		if moderationSeverity[variant.Result.Outcome] > moderationSeverity[merged.Outcome] {
+			merged.Outcome = variant.Result.Outcome
+		}
+		merged.Findings = append(merged.Findings, variant.Result.Findings...)
+	}
+	return merged
+}
+
+// @Desc: [Localisation] Record what the content filters found in each variant against the notification.
+func recordVariantModeration(db *sql.DB, teacher string, notificationId *int64, variants []variantModeration) error {
+	for _, variant := range variants {
+		if err := recordModeration(db, teacher, notificationId, nil, variant.Original, variant.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Localisation] Save the language variants of a notification.
+func saveVariants(db execer, notificationId int64, variants map[string]string) error {
+	for language, text := range variants {
+		_, err := db.Exec("INSERT INTO NotificationVariant(notification_id, language, message) VALUES(?, ?, ?)", notificationId, language, text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// @Desc: [Localisation] The language variants of a notification, keyed by language.
+func getVariants(db *sql.DB, notificationId int64) (map[string]string, error) {
+	rows, err := db.Query("SELECT language, message FROM NotificationVariant WHERE notification_id = ?", notificationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := make(map[string]string)
+	for rows.Next() {
+		var language, text string
+		if err := rows.Scan(&language, &text); err != nil {
+			return nil, err
+		}
+		variants[language] = text
+	}
+	return variants, rows.Err()
+}
+
+// @Desc: [Localisation] The text a student preferring `preferred` should receive: the variant in their language, else one in the same primary language,
+// else the one in the FallbackLanguage, else the notification as written.
+func chooseVariant(notification model.ScheduledNotification, variants map[string]string, preferred string) string {
+	if len(variants) == 0 {
+		return notification.Notification
+	}
+	texts := map[string]string{normaliseLanguage(notification.Language): notification.Notification}
+	for language, text := range variants {
+		texts[language] = text
+	}
+
+	preferred = normaliseLanguage(preferred)
+	if len(preferred) > 0 {
+		if text, ok := texts[preferred]; ok {
+			return text
+		}
+		if text, ok := texts[baseLanguage(preferred)]; ok {
+			return text
+		}
+		// Regional variants in the same language, e.g. zh-sg for a student preferring zh-tw, picked in a stable order
+		languages := make([]string, 0, len(texts))
+		for language := range texts {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+		for _, language := range languages {
+			if baseLanguage(language) == baseLanguage(preferred) {
+				return texts[language]
+			}
+		}
+	}
+	if text, ok := texts[config.FallbackLanguage]; ok {
+		return text
+	}
+	return notification.Notification
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Language Variants
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Each student should get the variant in their language, else one in the same primary language, else the fallback language.
+func TestChooseVariant(t *testing.T) {
+	notification := model.ScheduledNotification{Notification: "Zoo trip on Friday", Language: "ms"}
+	variants := map[string]string{"en": "Zoo trip on Friday (en)", "zh-sg": "动物园之旅在星期五", "ta": "வெள்ளிக்கிழமை"}
+
+	assert.Equal(t, "வெள்ளிக்கிழமை", chooseVariant(notification, variants, "ta"), "An exact match should be chosen.")
+	assert.Equal(t, "动物园之旅在星期五", chooseVariant(notification, variants, "zh-TW"), "A variant in the same primary language should be chosen.")
+	assert.Equal(t, "Zoo trip on Friday", chooseVariant(notification, variants, "ms-MY"), "The notification's own language should be chosen.")
+	assert.Equal(t, "Zoo trip on Friday (en)", chooseVariant(notification, variants, "fr"), "The fallback language should be chosen.")
+	assert.Equal(t, "Zoo trip on Friday (en)", chooseVariant(notification, variants, ""), "Students without a language should get the fallback.")
+	assert.Equal(t, "Zoo trip on Friday", chooseVariant(notification, nil, "ta"), "Notifications without variants should be sent as written.")
+	log.Println("SUCCESS: TestChooseVariant")
+}
+
+// @Desc: [FAIL] Variants with a malformed language, of the notification's own language or without text should be rejected.
+func TestValidateVariantsInvalid(t *testing.T) {
+	cases := []struct {
+		variants map[string]string
+		expected string
+	}{
+		{map[string]string{"chinese!": "你好"}, "Invalid variant language: chinese!."},
+		{map[string]string{"EN": "Hello"}, "Duplicate variant language: en."},
+		{map[string]string{"zh": "  "}, "Empty variant: zh."},
+	}
+	for _, c := range cases {
+		_, err := validateVariants(c.variants, "en", false)
+		assert.EqualError(t, err, c.expected)
+	}
+
+	validated, err := validateVariants(map[string]string{"zh-SG": "你好"}, "en", false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"zh-sg": "你好"}, validated, "Languages should be normalised.")
+	log.Println("SUCCESS: TestValidateVariantsInvalid")
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Formats a notification body can be written in
+const (
+	notificationFormatPlain    = "plain"
+	notificationFormatMarkdown = "markdown"
+)
+
+var (
+	markdown = goldmark.New()
+	// Raw HTML is already left out by the renderer, the policy also strips unsafe links & attributes
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// @Desc: [Markdown] Whether the format is one a notification can be written in.
+func isValidFormat(format string) bool {
+	return format == notificationFormatPlain || format == notificationFormatMarkdown
+}
+
+// @Desc: [Markdown] The plain text and sanitised HTML versions of a notification body. Plain notifications have no HTML version.
+func renderNotificationBody(body string, format string) (string, string) {
+	if format != notificationFormatMarkdown {
+		return body, ""
+	}
+	source := []byte(body)
+	document := markdown.Parser().Parse(text.NewReader(source))
+
+	var html bytes.Buffer
+	if err := markdown.Renderer().Render(&html, source, document); err != nil {
+		return body, ""
+	}
+	return strings.Join(markdownBlocks(document, source), "\n\n"), markdownPolicy.Sanitize(html.String())
+}
+
+// @Desc: [Markdown] The plain text version of a notification body, for channels that cannot show HTML.
+func notificationText(body string, format string) string {
+	plain, _ := renderNotificationBody(body, format)
+	return plain
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Plain Text Rendering
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Markdown] The plain text of each non-empty block under the node.
+func markdownBlocks(node ast.Node, source []byte) []string {
+	var blocks []string
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if block := markdownBlock(child, source); len(block) > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// @Desc: [Markdown] The plain text of a block, keeping list markers & line breaks but dropping other formatting.
+func markdownBlock(node ast.Node, source []byte) string {
+	switch node := node.(type) {
+	case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+		return markdownInline(node, source)
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var code strings.Builder
+		lines := node.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			code.Write(segment.Value(source))
+		}
+		return strings.TrimRight(code.String(), "\n")
+	case *ast.List:
+		var items []string
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "- "
+			if node.IsOrdered() {
+				marker = strconv.Itoa(number) + ". "
+				number++
+			}
+			content := strings.Join(markdownBlocks(item, source), "\n")
+			items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+strings.Repeat(" ", len(marker))))
+		}
+		return strings.Join(items, "\n")
+	case *ast.ThematicBreak:
+		return "---"
+	case *ast.HTMLBlock:
+		return ""
+	default:
+		return strings.Join(markdownBlocks(node, source), "\n\n")
+	}
+}
+
+// @Desc: [Markdown] The text of the inline content under the node. Links keep their destination after their text.
+func markdownInline(node ast.Node, source []byte) string {
+	var inline strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child := child.(type) {
+		case *ast.Text:
+			inline.Write(child.Segment.Value(source))
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				inline.WriteString("\n")
+			}
+		case *ast.String:
+			inline.Write(child.Value)
+		case *ast.AutoLink:
+			inline.Write(child.URL(source))
+		case *ast.Link:
+			label := markdownInline(child, source)
+			inline.WriteString(label)
+			if destination := string(child.Destination); destination != label {
+				inline.WriteString(" (" + destination + ")")
+			}
+		case *ast.RawHTML:
+		default:
+			inline.WriteString(markdownInline(child, source))
+		}
+	}
+	return inline.String()
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Markdown Notifications
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Markdown should render to HTML and to plain text keeping list markers, link destinations & mentions.
+func TestRenderMarkdownNotification(t *testing.T) {
+	text, html := renderNotificationBody("**Zoo trip** on Friday @s1@gmail.com\n\n1. Water bottle\n2. [Consent form](https://school.edu.sg/consent)", notificationFormatMarkdown)
+
+	assert.Equal(t, "Zoo trip on Friday @s1@gmail.com\n\n1. Water bottle\n2. Consent form (https://school.edu.sg/consent)", text, "Plain text should drop the formatting.")
+	assert.Contains(t, html, "<strong>Zoo trip</strong>")
+	assert.Contains(t, html, `<a href="https://school.edu.sg/consent" rel="nofollow">Consent form</a>`)
+	log.Println("SUCCESS: TestRenderMarkdownNotification")
+}
+
+// @Desc: [VALID] Raw HTML & script links in Markdown should never make it into the HTML, and plain notifications should be left untouched.
+func TestRenderMarkdownSanitised(t *testing.T) {
+	_, html := renderNotificationBody("Hello <script>alert(1)</script> [click](javascript:alert(1)) <img src=x onerror=alert(1)>", notificationFormatMarkdown)
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "javascript:")
+	assert.NotContains(t, html, "onerror")
+
+	text, html := renderNotificationBody("Hello **students**", notificationFormatPlain)
+	assert.Equal(t, "Hello **students**", text, "Plain notifications should be untouched.")
+	assert.Empty(t, html, "Plain notifications should have no HTML.")
+	log.Println("SUCCESS: TestRenderMarkdownSanitised")
+}
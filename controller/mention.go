@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Ways a mention can be rendered into text through the `render_mentions` option
+const (
+	renderMentionsName = "name"
+	renderMentionsLink = "link"
+)
+
+// Group mentions that expand server-side into a set of students
+const (
+	groupMentionAll     = "all"
+	groupMentionClass   = "class"
+	groupMentionCommon  = "common"
+	groupMentionTeacher = "teacher"
+)
+
+// A mention found in notification text. Offset and Length are in bytes of the original text and
+// cover the whole token including the leading '@', so text[Offset:Offset+Length] == "@" + Email
+// for a student mention, or "@" + Group for a group mention such as "all" or "class:3A".
+type mention struct {
+	Offset int
+	Length int
+	Email  string
+	Group  string
+}
+
+// A token that looked like a mention but does not hold a valid email address, e.g. "@" or "@notanemail".
+type invalidMention struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Mention Tokenizer
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [RetrieveForNotification] Tokenize notification text into valid email mentions and invalid '@' tokens, both in order of appearance.
+// A mention starts at an '@' that does not directly follow a letter or digit (so plain addresses such as t1@gmail.com are left alone),
+// runs until whitespace or a delimiter that cannot be part of an address, and has trailing punctuation such as "," "." or ")" trimmed.
+func parseMentions(text string) ([]mention, []invalidMention) {
+	var mentions []mention
+	var invalid []invalidMention
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != '@' || !isMentionBoundary(text[:i]) {
+			i += size
+			continue
+		}
+
+		// Consume the candidate up to the first delimiter
+		end := i + size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if isMentionDelimiter(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		// Trailing punctuation belongs to the sentence rather than the address
+		token := strings.TrimRightFunc(text[i+size:end], func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		length := size + len(token)
+
+		if isMentionEmail(token) {
+			mentions = append(mentions, mention{Offset: i, Length: length, Email: token})
+		} else if isGroupMention(token) {
+			mentions = append(mentions, mention{Offset: i, Length: length, Group: token})
+		} else {
+			invalid = append(invalid, invalidMention{Offset: i, Length: length, Text: text[i : i+length]})
+		}
+
+		// Resume right after the token so that trimmed punctuation can open the next mention
+		if length > size {
+			i += length
+		} else {
+			i = end
+		}
+	}
+	return mentions, invalid
+}
+
+// @Desc: [RetrieveForNotification] Unique group mentions in order of first appearance.
+func mentionedGroups(mentions []mention) []string {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, m := range mentions {
+		if len(m.Group) == 0 || seen[m.Group] {
+			continue
+		}
+		seen[m.Group] = true
+		groups = append(groups, m.Group)
+	}
+	return groups
+}
+
+// @Desc: [RetrieveForNotification] Unique mentioned emails in order of first appearance, compared case-insensitively.
+func mentionedEmails(mentions []mention) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	for _, m := range mentions {
+		key := strings.ToLower(m.Email)
+		if len(m.Group) > 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		emails = append(emails, m.Email)
+	}
+	return emails
+}
+
+// @Desc: [RetrieveForNotification] Mentions as spans of the original text, with offsets & lengths counted in characters (Unicode code points) rather than bytes.
+func mentionSpans(text string, mentions []mention) []model.MentionSpan {
+	spans := make([]model.MentionSpan, 0, len(mentions))
+	for _, m := range mentions {
+		spans = append(spans, model.MentionSpan{
+			Offset: utf8.RuneCountInString(text[:m.Offset]),
+			Length: utf8.RuneCountInString(text[m.Offset : m.Offset+m.Length]),
+			Email:  m.Email,
+			Group:  m.Group,
+		})
+	}
+	return spans
+}
+
+// @Desc: [RetrieveForNotification] Replace each mention in the text by the student's name or a mailto link, leaving everything else untouched.
+// Students missing from names are shown by their display name.
+func renderMentions(text string, mentions []mention, mode string, names map[string]string) string {
+	var rendered strings.Builder
+	last := 0
+	for _, m := range mentions {
+		rendered.WriteString(text[last:m.Offset])
+		mentionMode := mode
+		// Groups have neither a name nor an address to render
+		if len(m.Group) > 0 {
+			mentionMode = ""
+		}
+		switch mentionMode {
+		case renderMentionsName:
+			name, found := names[m.Email]
+			if !found {
+				name = studentDisplayName(m.Email)
+			}
+			rendered.WriteString("@" + name)
+		case renderMentionsLink:
+			rendered.WriteString("[@" + m.Email + "](mailto:" + m.Email + ")")
+		default:
+			rendered.WriteString(text[m.Offset : m.Offset+m.Length])
+		}
+		last = m.Offset + m.Length
+	}
+	rendered.WriteString(text[last:])
+	return rendered.String()
+}
+
+// @Desc: [RetrieveForNotification] Empty means no rendering was requested.
+func isValidMentionRendering(mode string) bool {
+	return mode == "" || mode == renderMentionsName || mode == renderMentionsLink
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [parseMentions] An '@' only opens a mention at the start of the text or after a character that cannot be part of a word.
+func isMentionBoundary(before string) bool {
+	if len(before) == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(before)
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '@' && r != '.' && r != '_' && r != '-' && r != '+'
+}
+
+// @Desc: [parseMentions] Characters that end a mention candidate.
+func isMentionDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(",;<>()[]{}\"", r) || r == utf8.RuneError
+}
+
+// @Desc: [parseMentions] The token must be a bare address with a local part and a domain, not a display name or a group.
+func isMentionEmail(token string) bool {
+	at := strings.LastIndexByte(token, '@')
+	if at <= 0 || at == len(token)-1 || !validEmailFormat(token) {
+		return false
+	}
+	address, err := mail.ParseAddress(token)
+	return err == nil && address.Name == "" && address.Address == token
+}
+
+// @Desc: [renderMentions] Students without a profile are only known by their email, so the local part stands in for their name.
+func studentDisplayName(email string) string {
+	return email[:strings.LastIndexByte(email, '@')]
+}
+
+// @Desc: [parseMentions] Group tokens are "all", "class:<name>", "common:<teacher email>" and its alias "teacher:<teacher email>".
+func isGroupMention(token string) bool {
+	kind, target, found := strings.Cut(token, ":")
+	if !found {
+		return token == groupMentionAll
+	}
+	switch kind {
+	case groupMentionClass:
+		return isValidClassName(target)
+	case groupMentionCommon, groupMentionTeacher:
+		return isMentionEmail(target)
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Mention Tokenizer
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Mentions followed by punctuation, newlines or repeated spaces should still resolve to the bare email, while '@' tokens without an email should be reported as invalid.
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		text     string
+		emails   []string
+		invalids []string
+	}{
+		{"hello world bye @s1@gmail.com @s2@gmail.com", []string{"s1@gmail.com", "s2@gmail.com"}, nil},
+		{"hi @s1@gmail.com, @s2@gmail.com.", []string{"s1@gmail.com", "s2@gmail.com"}, nil},
+		{"hi @s1@gmail.com\n@s2@gmail.com\t bye", []string{"s1@gmail.com", "s2@gmail.com"}, nil},
+		{"hi   @s1@gmail.com   bye", []string{"s1@gmail.com"}, nil},
+		{"(@s1@gmail.com) @s2@gmail.com,@s3@gmail.com!", []string{"s1@gmail.com", "s2@gmail.com", "s3@gmail.com"}, nil},
+		{"email t1@gmail.com directly", nil, nil},
+		{"meet @ 3pm @notanemail @", nil, []string{"@", "@notanemail", "@"}},
+		{"héllo @élève@école.fr 👋", []string{"élève@école.fr"}, nil},
+		{"@s1@gmail.com @S1@gmail.com", []string{"s1@gmail.com", "S1@gmail.com"}, nil},
+		{"@all, @class:3A and @common:t2@gmail.com", []string{"", "", ""}, nil},
+		{"@everyone @class: @common:t2", nil, []string{"@everyone", "@class", "@common:t2"}},
+	}
+
+	for _, c := range cases {
+		mentions, invalid := parseMentions(c.text)
+
+		var emails []string
+		for _, m := range mentions {
+			emails = append(emails, m.Email)
+		}
+		var invalids []string
+		for _, m := range invalid {
+			invalids = append(invalids, m.Text)
+		}
+
+		assert.Equal(t, c.emails, emails, "Mentioned emails of %q", c.text)
+		assert.Equal(t, c.invalids, invalids, "Invalid mentions of %q", c.text)
+	}
+	log.Println("SUCCESS: TestParseMentions")
+}
+
+// @Desc: [VALID] Group mentions should be recognised and kept apart from the mentioned student emails.
+func TestParseGroupMentions(t *testing.T) {
+	mentions, invalid := parseMentions("@all, @class:3A @common:t2@gmail.com @teacher:t3@gmail.com. @s1@gmail.com @class:3A")
+
+	assert.Empty(t, invalid, "All mentions should be valid.")
+	assert.Equal(t, []string{"all", "class:3A", "common:t2@gmail.com", "teacher:t3@gmail.com"}, mentionedGroups(mentions), "Groups should be unique and in order.")
+	assert.Equal(t, []string{"s1@gmail.com"}, mentionedEmails(mentions), "Group mentions should not be treated as emails.")
+	log.Println("SUCCESS: TestParseGroupMentions")
+}
+
+// @Desc: [VALID] Mentioned emails should be unique regardless of case, keeping the first spelling.
+func TestMentionedEmailsAreUnique(t *testing.T) {
+	mentions, _ := parseMentions("@s1@gmail.com @S1@GMAIL.COM @s2@gmail.com @s1@gmail.com")
+
+	assert.Equal(t, []string{"s1@gmail.com", "s2@gmail.com"}, mentionedEmails(mentions), "Emails should be de-duplicated.")
+	log.Println("SUCCESS: TestMentionedEmailsAreUnique")
+}
+
+// @Desc: [VALID] Spans should count characters rather than bytes, and rendering should only touch the mentions while keeping newlines and punctuation.
+func TestMentionSpansAndRendering(t *testing.T) {
+	text := "Héllo\n@s1@gmail.com, see you  @s2@gmail.com!"
+	mentions, _ := parseMentions(text)
+
+	spans := mentionSpans(text, mentions)
+	assert.Equal(t, 6, spans[0].Offset, "First mention should start after 6 characters.")
+	assert.Equal(t, 13, spans[0].Length, "Mention length should include the '@'.")
+	assert.Equal(t, 30, spans[1].Offset, "Second mention offset should be in characters.")
+
+	assert.Equal(t, "Héllo\n@s1, see you  @s2!", renderMentions(text, mentions, renderMentionsName, nil), "Mentions should render as names.")
+	assert.Equal(t, "Héllo\n[@s1@gmail.com](mailto:s1@gmail.com), see you  [@s2@gmail.com](mailto:s2@gmail.com)!",
+		renderMentions(text, mentions, renderMentionsLink, nil), "Mentions should render as links.")
+	log.Println("SUCCESS: TestMentionSpansAndRendering")
+}
+
+// @Desc: [FUZZ] Every mention must point back at its own '@' token in the text, hold a valid email, and never overlap another token.
+func FuzzParseMentions(f *testing.F) {
+	seeds := []string{
+		"hello world bye @s1@gmail.com @s2@gmail.com @s3@gmail.com",
+		"@s1@gmail.com,\n@s2@gmail.com.",
+		"@ @@ @notanemail @a@b@c (@x@y.z)",
+		"héllo @élève@école.fr 👋",
+		"\xff@a@b.com\xfe",
+		"@all, @class:3A @common:t2@gmail.com @teacher:t3@gmail.com. @class: @common:x",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		mentions, invalid := parseMentions(text)
+
+		last := 0
+		for _, m := range mentions {
+			if m.Offset < last || m.Offset+m.Length > len(text) {
+				t.Fatalf("mention %+v out of order or out of bounds in %q", m, text)
+			}
+			if text[m.Offset:m.Offset+m.Length] != "@"+m.Email+m.Group || (len(m.Email) > 0) == (len(m.Group) > 0) {
+				t.Fatalf("mention %+v does not match its span in %q", m, text)
+			}
+			if len(m.Group) > 0 {
+				if !isGroupMention(m.Group) {
+					t.Fatalf("mention %+v is not a valid group", m)
+				}
+			} else if !validEmailFormat(m.Email) || strings.ContainsAny(m.Email, " \t\r\n,;<>()") {
+				t.Fatalf("mention %+v is not a bare valid email", m)
+			}
+			last = m.Offset + m.Length
+		}
+
+		for _, m := range invalid {
+			if m.Offset+m.Length > len(text) || text[m.Offset:m.Offset+m.Length] != m.Text || !strings.HasPrefix(m.Text, "@") {
+				t.Fatalf("invalid mention %+v does not match its span in %q", m, text)
+			}
+		}
+
+		if utf8.ValidString(text) {
+			for _, mode := range []string{renderMentionsName, renderMentionsLink} {
+				if rendered := renderMentions(text, mentions, mode, nil); !utf8.ValidString(rendered) {
+					t.Fatalf("rendered %q of %q is not valid UTF-8", rendered, text)
+				}
+			}
+		}
+		if renderMentions(text, mentions, "", nil) != text {
+			t.Fatalf("rendering without a mode should keep %q untouched", text)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Outcomes of moderating a notification, from least to most severe. A filter's findings carry one of the actions warn, redact or block.
+const (
+	moderationAllow  = "allow"
+	moderationWarn   = "warn"
+	moderationRedact = "redact"
+	moderationBlock  = "block"
+)
+
+var moderationSeverity = map[string]int{
+	moderationAllow:  0,
+	moderationWarn:   1,
+	moderationRedact: 2,
+	moderationBlock:  3,
+}
+
+// ContentFilter: A check every notification goes through before it is sent. Returns the text with any redactions applied, along with what it found.
+type ContentFilter interface {
+	Name() string
+	Filter(text string) (string, []model.ModerationFinding)
+}
+
+var (
+	contentFiltersMu sync.RWMutex
+	contentFilters   = []ContentFilter{MustRuleFilter("rules", config.ModerationRules)}
+)
+
+// @Desc: Check every notification sent from now on with another filter, after the built-in rules. Call during start up.
+func RegisterContentFilter(filter ContentFilter) {
+	contentFiltersMu.Lock()
+	defer contentFiltersMu.Unlock()
+	contentFilters = append(contentFilters, filter)
+}
+
+// @Desc: [Moderation] All registered content filters, in the order they run.
+func registeredContentFilters() []ContentFilter {
+	contentFiltersMu.RLock()
+	defer contentFiltersMu.RUnlock()
+	return append([]ContentFilter(nil), contentFilters...)
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Rule Filter
+//////////////////////////////////////////////////////////////*/
+
+type moderationRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Action  string
+}
+
+// RuleFilter: The built-in filter, matching notifications against a list of regular expressions
+type RuleFilter struct {
+	name  string
+	rules []moderationRule
+}
+
+func NewRuleFilter(name string, rules []config.ModerationRule) (*RuleFilter, error) {
+	filter := &RuleFilter{name: name}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("moderation rule %s: %w", rule.Name, err)
+		}
+		if rule.Action != moderationWarn && rule.Action != moderationRedact && rule.Action != moderationBlock {
+			return nil, fmt.Errorf("moderation rule %s: unknown action %q", rule.Name, rule.Action)
+		}
+		filter.rules = append(filter.rules, moderationRule{Name: rule.Name, Pattern: pattern, Action: rule.Action})
+	}
+	return filter, nil
+}
+
+// @Desc: Like NewRuleFilter, but panics on an invalid rule, for rules fixed in the configuration.
+func MustRuleFilter(name string, rules []config.ModerationRule) *RuleFilter {
+	filter, err := NewRuleFilter(name, rules)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+func (f *RuleFilter) Name() string {
+	return f.name
+}
+
+// @Desc: [RuleFilter] Redacted matches are masked character for character, so that the mentions around them keep their offsets.
+func (f *RuleFilter) Filter(text string) (string, []model.ModerationFinding) {
+	var findings []model.ModerationFinding
+	for _, rule := range f.rules {
+		for _, match := range rule.Pattern.FindAllString(text, -1) {
+			findings = append(findings, model.ModerationFinding{Filter: f.name, Rule: rule.Name, Action: rule.Action, Match: match})
+		}
+		if rule.Action == moderationRedact {
+			text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+				return strings.Repeat("*", utf8.RuneCountInString(match))
+			})
+		}
+	}
+	return text, findings
+}
+
+/*///////////////////////////////////////////////////////////////
+                         Moderation Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// ModerationHistory: List what the content filters found in a teacher's notifications, newest first
+// URL : /teachers/{teacher}/moderation
+// Parameters: teacher
+// Method: GET
+// Output: JSON Encoded Array of moderation records, else error message.
+func ModerationHistory(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+	if !validEmailFormat(teacher) {
+		ErrorResponse("Invalid teacher email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, notification_id, recurring_id, teacher, message, outcome, findings, created_at FROM ContentModeration
+	WHERE teacher = ? ORDER BY created_at DESC, id DESC`, teacher)
+	if err != nil {
+		ErrorResponse("Failed to retrieve moderation history.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	records := make([]model.ModerationRecord, 0)
+	for rows.Next() {
+		var record model.ModerationRecord
+		var notificationId, recurringId sql.NullInt64
+		var findings string
+		err := rows.Scan(&record.Id, &notificationId, &recurringId, &record.Teacher, &record.Notification, &record.Outcome, &findings, &record.CreatedAt)
+		if err == nil {
+			err = json.Unmarshal([]byte(findings), &record.Findings)
+		}
+		if err != nil {
+			ErrorResponse("Failed to retrieve moderation history.", w, http.StatusNotFound)
+			return
+		}
+		if notificationId.Valid {
+			record.NotificationId = &notificationId.Int64
+		}
+		if recurringId.Valid {
+			record.RecurringId = &recurringId.Int64
+		}
+		records = append(records, record)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(records)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Moderation] Run the text through every content filter in turn. The outcome is the most severe action found, and the text comes back with every redaction applied.
+func moderateContent(text string) (string, model.ModerationResult) {
+	result := model.ModerationResult{Outcome: moderationAllow, Findings: make([]model.ModerationFinding, 0)}
+	for _, filter := range registeredContentFilters() {
+		var findings []model.ModerationFinding
+		text, findings = filter.Filter(text)
+		for _, finding := range findings {
+			if moderationSeverity[finding.Action] > moderationSeverity[result.Outcome] {
+				result.Outcome = finding.Action
+			}
+		}
+		result.Findings = append(result.Findings, findings...)
+	}
+	return text, result
+}
+
+// @Desc: [Moderation] What to return to the teacher along with the notification, nothing when the filters found nothing.
+func moderationSummary(result model.ModerationResult) *model.ModerationResult {
+	if result.Outcome == moderationAllow {
+		return nil
+	}
+	return &result
+}
+
+// @Desc: [Moderation] Record the original text of a notification the filters found something in, against the notification or recurring notification it became, if any.
+func recordModeration(db *sql.DB, teacher string, notificationId *int64, recurringId *int64, original string, result model.ModerationResult) error {
+	if result.Outcome == moderationAllow {
+		return nil
+	}
+	findings, err := json.Marshal(result.Findings)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO ContentModeration(notification_id, recurring_id, teacher, message, outcome, findings, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		notificationId, recurringId, teacher, original, result.Outcome, string(findings), time.Now().UTC())
+	return err
+}
+
+// @Desc: [Moderation] Record a blocked notification and tell the teacher what blocked it.
+func moderationBlockedResponse(db *sql.DB, teacher string, original string, result model.ModerationResult, w http.ResponseWriter) {
+	if err := recordModeration(db, teacher, nil, nil, original, result); err != nil {
+		ErrorResponse("Failed to moderate notification.", w, http.StatusNotFound)
+		return
+	}
+	writeModerationBlocked(result, w)
+}
+
+// @Desc: [Moderation] Tell the teacher what blocked their notification.
+func writeModerationBlocked(result model.ModerationResult, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(model.ModerationBlockedResponse{Message: "Notification blocked by content filter.", Moderation: result})
+}
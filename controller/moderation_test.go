@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Content Moderation
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Redacted words should be masked character for character, leaving mentions intact, and the outcome should be the most severe action found.
+func TestRuleFilterOutcomes(t *testing.T) {
+	filter := MustRuleFilter("rules", []config.ModerationRule{
+		{Name: "profanity", Pattern: `(?i)\bdamn\b`, Action: "redact"},
+		{Name: "phone-number", Pattern: `\b[689]\d{7}\b`, Action: "warn"},
+	})
+
+	text, findings := filter.Filter("Damn it @s1@gmail.com, call 91234567")
+	assert.Equal(t, "**** it @s1@gmail.com, call 91234567", text, "Profanity should be masked.")
+	assert.Equal(t, []model.ModerationFinding{
+		{Filter: "rules", Rule: "profanity", Action: "redact", Match: "Damn"},
+		{Filter: "rules", Rule: "phone-number", Action: "warn", Match: "91234567"},
+	}, findings, "Both rules should be found.")
+
+	_, err := NewRuleFilter("rules", []config.ModerationRule{{Name: "bad", Pattern: "x", Action: "shout"}})
+	assert.Error(t, err, "An unknown action should be rejected.")
+	log.Println("SUCCESS: TestRuleFilterOutcomes")
+}
+
+// @Desc: [VALID] Notifications with an NRIC number should be blocked by the built-in rules, while clean ones are allowed untouched.
+func TestModerateContent(t *testing.T) {
+	text, result := moderateContent("Please bring your IC, S1234567D, on Friday")
+	assert.Equal(t, moderationBlock, result.Outcome, "An NRIC number should be blocked.")
+	assert.Equal(t, "nric", result.Findings[0].Rule)
+
+	text, result = moderateContent("Hello students! @s1@gmail.com")
+	assert.Equal(t, "Hello students! @s1@gmail.com", text, "Clean text should be untouched.")
+	assert.Equal(t, moderationAllow, result.Outcome)
+	assert.Nil(t, moderationSummary(result), "Nothing should be reported for clean text.")
+	log.Println("SUCCESS: TestModerateContent")
+}
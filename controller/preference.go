@@ -0,0 +1,431 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const quietHoursLayout = "15:04"
+
+/*///////////////////////////////////////////////////////////////
+                    Notification Preference Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentPreferences: Get the notification preferences of a student
+// URL : /students/{student}/preferences
+// Parameters: student
+// Method: GET
+// Output: JSON Encoded Object of the student's preferences, else error message.
+func StudentPreferences(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	preferences, err := getStudentPreferences(db, student)
+	if err != nil {
+		ErrorResponse("Failed to get preferences.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(preferences)
+}
+
+// UpdateStudentPreferences: Replace the notification preferences of a student
+// URL : /students/{student}/preferences
+// Parameters: opted_out, muted_teachers, muted_channels, quiet_hours, digest
+// Method: PUT
+// Output: JSON Encoded Object of the saved preferences, else error message.
+func UpdateStudentPreferences(w http.ResponseWriter, r *http.Request) {
+	var preferences model.StudentPreferences
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&preferences)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	preferences.Student = student
+	preferences.Language = normaliseLanguage(preferences.Language)
+
+	if err := validatePreferences(preferences); err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if err := saveStudentPreferences(db, preferences); err != nil {
+		ErrorResponse("Failed to save preferences.", w, http.StatusNotFound)
+		return
+	}
+
+	saved, err := getStudentPreferences(db, student)
+	if err != nil {
+		ErrorResponse("Failed to get preferences.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// UnsubscribePage: Ask the student to confirm opting out through a signed link. Fetching the link changes nothing, so that mail scanners cannot unsubscribe anyone
+// URL : /unsubscribe
+// Parameters: token
+// Method: GET
+// Output: HTML page with a button posting the unsubscribe, else error message.
+func UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	student, teacher, err := parseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		ErrorResponse("Invalid unsubscribe token.", w, http.StatusBadRequest)
+		return
+	}
+
+	question := "Stop all notifications to " + student + "?"
+	if len(teacher) > 0 {
+		question = "Stop notifications from " + teacher + " to " + student + "?"
+	}
+	// The form posts back to this URL, token included
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<p>%s</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+</body>
+</html>
+`, html.EscapeString(question))
+}
+
+// Unsubscribe: Opt a student out of a teacher's notifications (or all notifications) through a signed link, as confirmed on its page
+// or posted by the email client for a one-click unsubscribe (RFC 8058)
+// URL : /unsubscribe
+// Parameters: token
+// Method: POST
+// Output: Success message if successful, else error message.
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	student, teacher, err := parseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		ErrorResponse("Invalid unsubscribe token.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if len(teacher) == 0 {
+		_, err = db.Exec(`INSERT INTO StudentPreference(student, opted_out) VALUES(?, 1) ON DUPLICATE KEY UPDATE opted_out = 1`, student)
+	} else {
+		_, err = db.Exec(`INSERT INTO TeacherOptOut(student, teacher) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM TeacherOptOut WHERE student = ? AND teacher = ?)`,
+			student, teacher, student, teacher)
+	}
+	if err != nil {
+		ErrorResponse("Failed to unsubscribe.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if len(teacher) == 0 {
+		WriteSuccessResponse("Unsubscribed from all notifications.", w)
+		return
+	}
+	WriteSuccessResponse("Unsubscribed from notifications by "+teacher+".", w)
+}
+
+/*///////////////////////////////////////////////////////////////
+                       Preference Persistence
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Preferences] Load the preferences of a student, defaulting to receiving everything when none were saved.
+func getStudentPreferences(db *sql.DB, student string) (model.StudentPreferences, error) {
+	preferences := model.StudentPreferences{Student: student, Digest: digestOff}
+
+	var quietStart, quietEnd, quietTimezone, language sql.NullString
+	err := db.QueryRow("SELECT opted_out, quiet_start, quiet_end, quiet_timezone, digest, language FROM StudentPreference WHERE student = ?", student).
+		Scan(&preferences.OptedOut, &quietStart, &quietEnd, &quietTimezone, &preferences.Digest, &language)
+	if err != nil && err != sql.ErrNoRows {
+		return preferences, err
+	}
+	if quietStart.Valid && quietEnd.Valid {
+		preferences.QuietHours = &model.QuietHours{Start: quietStart.String, End: quietEnd.String, Timezone: quietTimezone.String}
+	}
+	preferences.Language = language.String
+
+	preferences.MutedTeachers, err = queryColumn(db, "SELECT teacher FROM TeacherOptOut WHERE student = ? ORDER BY teacher", student)
+	if err != nil {
+		return preferences, err
+	}
+	preferences.MutedChannels, err = queryColumn(db, "SELECT channel FROM ChannelOptOut WHERE student = ? ORDER BY channel", student)
+	return preferences, err
+}
+
+// @Desc: [Preferences] Replace every saved preference of a student within one transaction.
+func saveStudentPreferences(db *sql.DB, preferences model.StudentPreferences) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var quietStart, quietEnd, quietTimezone sql.NullString
+	if preferences.QuietHours != nil {
+		quietStart = sql.NullString{String: preferences.QuietHours.Start, Valid: true}
+		quietEnd = sql.NullString{String: preferences.QuietHours.End, Valid: true}
+		quietTimezone = sql.NullString{String: preferences.QuietHours.Timezone, Valid: true}
+	}
+
+	digest := preferences.Digest
+	if len(digest) == 0 {
+		digest = digestOff
+	}
+	var language sql.NullString
+	if len(preferences.Language) > 0 {
+		language = sql.NullString{String: preferences.Language, Valid: true}
+	}
+
+	_, err = tx.Exec(`INSERT INTO StudentPreference(student, opted_out, quiet_start, quiet_end, quiet_timezone, digest, language) VALUES(?, ?, ?, ?, ?, ?, ?)
+	ON DUPLICATE KEY UPDATE opted_out = VALUES(opted_out), quiet_start = VALUES(quiet_start), quiet_end = VALUES(quiet_end), quiet_timezone = VALUES(quiet_timezone),
+	digest = VALUES(digest), language = VALUES(language)`,
+		preferences.Student, preferences.OptedOut, quietStart, quietEnd, quietTimezone, digest, language)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM TeacherOptOut WHERE student = ?", preferences.Student); err != nil {
+		return err
+	}
+	for _, teacher := range uniqueStrings(preferences.MutedTeachers) {
+		if _, err = tx.Exec("INSERT INTO TeacherOptOut(student, teacher) VALUES(?, ?)", preferences.Student, teacher); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec("DELETE FROM ChannelOptOut WHERE student = ?", preferences.Student); err != nil {
+		return err
+	}
+	for _, channel := range uniqueStrings(preferences.MutedChannels) {
+		if _, err = tx.Exec("INSERT INTO ChannelOptOut(student, channel) VALUES(?, ?)", preferences.Student, channel); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*///////////////////////////////////////////////////////////////
+                            Quiet Hours
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Scheduler] Push every notification held back during a recipient's quiet hours once they are over, unless it was recalled or expired since,
+// then the messages held back for their guardians.
+func sendHeldPushes(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`SELECT Message.id, Message.teacher, COALESCE(Recipient.message, Message.message), Message.format, Recipient.student
+	FROM NotificationRecipient AS Recipient JOIN NotificationMessage AS Message ON Message.id = Recipient.notification_id
+	WHERE Recipient.push_held = 1 AND Message.status = ? AND (Message.expires_at IS NULL OR Message.expires_at > ?)`, notificationStatusSent, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	var held []channelMessage
+	for rows.Next() {
+		var message channelMessage
+		var format string
+		if err := rows.Scan(&message.NotificationId, &message.Teacher, &message.Text, &format, &message.Student); err != nil {
+			rows.Close()
+			return err
+		}
+		message.Text, message.HTML = renderNotificationBody(message.Text, format)
+		held = append(held, message)
+	}
+	rows.Close()
+
+	for _, message := range held {
+		preferences, err := getStudentPreferences(db, message.Student)
+		if err != nil {
+			return err
+		}
+		// Picked up again on a later tick once quiet hours are over
+		if inQuietHours(preferences, now) {
+			continue
+		}
+
+		// Claim the push first so that another scheduler cannot send it twice
+		result, err := db.Exec("UPDATE NotificationRecipient SET push_held = 0 WHERE notification_id = ? AND student = ? AND push_held = 1", message.NotificationId, message.Student)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+
+		attachments, err := getAttachments(db, message.NotificationId)
+		if err != nil {
+			return err
+		}
+		message.Attachments = signAttachments(attachments, message.Student)
+		pushNotification(db, message, preferences)
+	}
+	return sendHeldGuardianPushes(db, now)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Preferences] Reject unknown channels, malformed teacher emails and malformed quiet hours, defaulting the quiet hours timezone to UTC.
+func validatePreferences(preferences model.StudentPreferences) error {
+	for _, teacher := range preferences.MutedTeachers {
+		if !validEmailFormat(teacher) {
+			return errors.New("Invalid teacher email format.")
+		}
+	}
+	for _, channel := range preferences.MutedChannels {
+		if !isRegisteredChannel(channel) {
+			return errors.New("Unknown channel: " + channel + ".")
+		}
+	}
+	if len(preferences.Digest) > 0 && !isDigestFrequency(preferences.Digest) {
+		return errors.New("Invalid digest, expected off, hourly or daily.")
+	}
+	if len(preferences.Language) > 0 && !isValidLanguage(preferences.Language) {
+		return errors.New("Invalid language.")
+	}
+	if quiet := preferences.QuietHours; quiet != nil {
+		if len(quiet.Timezone) == 0 {
+			quiet.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(quiet.Timezone); err != nil {
+			return errors.New("Invalid timezone.")
+		}
+		_, startErr := time.Parse(quietHoursLayout, quiet.Start)
+		_, endErr := time.Parse(quietHoursLayout, quiet.End)
+		if startErr != nil || endErr != nil {
+			return errors.New("Invalid quiet hours, expected HH:MM.")
+		}
+	}
+	return nil
+}
+
+// @Desc: [Preferences] Whether the student muted the channel.
+func mutesChannel(preferences model.StudentPreferences, channel string) bool {
+	for _, muted := range preferences.MutedChannels {
+		if muted == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// @Desc: [Preferences] Whether `now` falls within the student's quiet hours. Quiet hours may wrap past midnight, e.g. 22:00 to 07:00.
+func inQuietHours(preferences model.StudentPreferences, now time.Time) bool {
+	quiet := preferences.QuietHours
+	if quiet == nil {
+		return false
+	}
+	location, err := time.LoadLocation(quiet.Timezone)
+	if err != nil {
+		return false
+	}
+	start, startErr := time.Parse(quietHoursLayout, quiet.Start)
+	end, endErr := time.Parse(quietHoursLayout, quiet.End)
+	if startErr != nil || endErr != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
+// @Desc: [Unsubscribe] A link that opts the student out of the teacher's notifications, or of all notifications when teacher is empty.
+func unsubscribeURL(student string, teacher string) string {
+	return config.PublicBaseURL + "/api/unsubscribe?token=" + url.QueryEscape(unsubscribeToken(student, teacher))
+}
+
+// @Desc: [Unsubscribe] Token of the form <payload>.<signature>, both base64url encoded, where the payload is "<student>\n<teacher>".
+func unsubscribeToken(student string, teacher string) string {
+	payload := []byte(student + "\n" + teacher)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signUnsubscribePayload(payload))
+}
+
+// @Desc: [Unsubscribe] Verify an unsubscribe token and return the student & teacher it was issued for.
+func parseUnsubscribeToken(token string) (string, string, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return "", "", errors.New("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", "", err
+	}
+	if !hmac.Equal(signature, signUnsubscribePayload(payload)) {
+		return "", "", errors.New("invalid signature")
+	}
+
+	student, teacher, _ := strings.Cut(string(payload), "\n")
+	if !validEmailFormat(student) {
+		return "", "", errors.New("invalid student")
+	}
+	return student, teacher, nil
+}
+
+func signUnsubscribePayload(payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(config.UnsubscribeSecret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// @Desc: Remove repeated values while keeping the order of first appearance.
+func uniqueStrings(values []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Preferences
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Saving preferences with malformed quiet hours or an unknown channel, which should fail with HTTP code 400.
+func TestUpdateStudentPreferencesInvalid(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"quiet_hours":{"start":"10pm","end":"07:00"}}`, `{"message":"Invalid quiet hours, expected HH:MM."}`},
+		{`{"quiet_hours":{"start":"22:00","end":"07:00","timezone":"Mars/Base"}}`, `{"message":"Invalid timezone."}`},
+		{`{"muted_channels":["pigeon"]}`, `{"message":"Unknown channel: pigeon."}`},
+		{`{"muted_teachers":["t1"]}`, `{"message":"Invalid teacher email format."}`},
+		{`{"digest":"weekly"}`, `{"message":"Invalid digest, expected off, hourly or daily."}`},
+		{`{"language":"english!"}`, `{"message":"Invalid language."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("PUT", "/api/students/s1@gmail.com/preferences", bytes.NewBuffer([]byte(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com"})
+
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(UpdateStudentPreferences)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestUpdateStudentPreferencesInvalid")
+}
+
+// @Desc: [VALID] Quiet hours should hold within the student's timezone, including when they wrap past midnight.
+func TestInQuietHours(t *testing.T) {
+	overnight := model.StudentPreferences{QuietHours: &model.QuietHours{Start: "22:00", End: "07:00", Timezone: "Asia/Singapore"}}
+	daytime := model.StudentPreferences{QuietHours: &model.QuietHours{Start: "09:00", End: "17:00", Timezone: "UTC"}}
+
+	// 15:00 UTC is 23:00 in Singapore
+	assert.True(t, inQuietHours(overnight, time.Date(2023, 2, 15, 15, 0, 0, 0, time.UTC)), "23:00 should be quiet.")
+	assert.True(t, inQuietHours(overnight, time.Date(2023, 2, 15, 22, 59, 0, 0, time.UTC)), "06:59 should be quiet.")
+	assert.False(t, inQuietHours(overnight, time.Date(2023, 2, 15, 23, 0, 0, 0, time.UTC)), "07:00 should not be quiet.")
+	assert.True(t, inQuietHours(daytime, time.Date(2023, 2, 15, 9, 0, 0, 0, time.UTC)), "09:00 should be quiet.")
+	assert.False(t, inQuietHours(daytime, time.Date(2023, 2, 15, 17, 0, 0, 0, time.UTC)), "17:00 should not be quiet.")
+	assert.False(t, inQuietHours(model.StudentPreferences{}, time.Now()), "No quiet hours should never be quiet.")
+	log.Println("SUCCESS: TestInQuietHours")
+}
+
+// @Desc: [VALID] Unsubscribe tokens should round trip, while tampered tokens should be rejected with HTTP code 400.
+func TestUnsubscribeToken(t *testing.T) {
+	token := unsubscribeToken("s1@gmail.com", "t1@gmail.com")
+	student, teacher, err := parseUnsubscribeToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "s1@gmail.com", student, "Student should round trip.")
+	assert.Equal(t, "t1@gmail.com", teacher, "Teacher should round trip.")
+
+	forged := unsubscribeToken("s2@gmail.com", "t1@gmail.com")
+	tampered := strings.Split(forged, ".")[0] + "." + strings.Split(token, ".")[1]
+	req, err := http.NewRequest("POST", "/api/unsubscribe?token="+tampered, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Unsubscribe)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid unsubscribe token."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestUnsubscribeToken")
+}
+
+// @Desc: [VALID] Following an unsubscribe link should only show a page confirming it, posting back to the same link.
+func TestUnsubscribePageConfirms(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/unsubscribe?token="+unsubscribeToken("s1@gmail.com", "t1@gmail.com"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(UnsubscribePage)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "Stop notifications from t1@gmail.com to s1@gmail.com?")
+	assert.Contains(t, rr.Body.String(), `<form method="post">`)
+	log.Println("SUCCESS: TestUnsubscribePageConfirms")
+}
+
+// @Desc: [FAIL] Emails should refuse teachers that could inject headers, and encode subjects that are not ASCII.
+func TestBuildEmailHeaders(t *testing.T) {
+	for _, teacher := range []string{"t1@gmail.com\r\nBcc: everyone@gmail.com", "Ken <t1@gmail.com>"} {
+		_, err := buildEmail("school@gmail.com", channelMessage{Teacher: teacher, Student: "s1@gmail.com", Text: "Zoo trip"})
+		assert.Error(t, err, teacher)
+	}
+
+	built, err := buildEmail("school@gmail.com", channelMessage{Teacher: "t1@gmail.com", Student: "s1@gmail.com", Subject: "Réunion des parents", Text: "Zoo trip"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(built), "Subject: =?UTF-8?q?R=C3=A9union_des_parents?=\r\n")
+	assert.Contains(t, string(built), "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	log.Println("SUCCESS: TestBuildEmailHeaders")
+}
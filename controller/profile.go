@@ -0,0 +1,522 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const profileColumns = "id, email, name, status, metadata, created_at, updated_at"
+
+// Teachers & students are active unless made inactive. Inactive teachers cannot send notifications, and inactive students are not sent any
+const (
+	profileStatusActive   = "active"
+	profileStatusInactive = "inactive"
+)
+
+const (
+	maxProfileNameLength     = 100
+	maxProfileMetadata       = 20
+	maxProfileMetadataKey    = 50
+	maxProfileMetadataLength = 500
+)
+
+// The table a kind of profile is kept in, the tables referencing it by email that prevent deleting it,
+// and the tables of its own settings that are deleted along with it
+type profileKind struct {
+	Table      string
+	Noun       string
+	References []string
+	Owned      []string
+}
+
+var (
+	teacherProfiles = profileKind{
+		Table:      "Teacher",
+		Noun:       "teacher",
+		References: []string{"Teach", "Notification", "NotificationMessage", "RecurringNotification"},
+		Owned:      []string{"NotificationTemplate", "RateLimitOverride", "TeacherOptOut"},
+	}
+	studentProfiles = profileKind{
+		Table:      "Student",
+		Noun:       "student",
+		References: []string{"Teach", "Notification", "Suspend", "ClassMember", "StudentGuardian", "NotificationRecipient", "NotificationReply", "DigestEntry", "Digest"},
+		Owned:      []string{"StudentPreference", "StudentContact", "TeacherOptOut", "ChannelOptOut"},
+	}
+)
+
+/*///////////////////////////////////////////////////////////////
+                          Teacher Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// CreateTeacher: Save the profile of a teacher
+// URL : /teachers
+// Parameters: email, name, status, metadata
+// Method: POST
+// Output: JSON Encoded Object of the saved teacher, else error message.
+func CreateTeacher(w http.ResponseWriter, r *http.Request) {
+	createProfile(teacherProfiles, w, r)
+}
+
+// ListTeachers: List the profiles of all teachers by email
+// URL : /teachers
+// Parameters: status
+// Method: GET
+// Output: JSON Encoded Array of teachers, else error message.
+func ListTeachers(w http.ResponseWriter, r *http.Request) {
+	listProfiles(teacherProfiles, w, r)
+}
+
+// GetTeacher: Retrieve the profile of a teacher
+// URL : /teachers/{teacher}
+// Parameters: teacher (email or id)
+// Method: GET
+// Output: JSON Encoded Object of the teacher, else error message.
+func GetTeacher(w http.ResponseWriter, r *http.Request) {
+	getProfileResponse(teacherProfiles, mux.Vars(r)["teacher"], w)
+}
+
+// UpdateTeacher: Replace the name, status & metadata of a teacher. Emails cannot be changed
+// URL : /teachers/{teacher}
+// Parameters: name, status, metadata
+// Method: PUT
+// Output: JSON Encoded Object of the updated teacher, else error message.
+func UpdateTeacher(w http.ResponseWriter, r *http.Request) {
+	updateProfile(teacherProfiles, mux.Vars(r)["teacher"], w, r)
+}
+
+// DeleteTeacher: Delete the profile of a teacher with no students or notifications, along with their templates & rate limit
+// URL : /teachers/{teacher}
+// Parameters: teacher (email or id)
+// Method: DELETE
+// Output: No content if successful, else error message.
+func DeleteTeacher(w http.ResponseWriter, r *http.Request) {
+	deleteProfile(teacherProfiles, mux.Vars(r)["teacher"], w)
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Student Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// CreateStudent: Save the profile of a student
+// URL : /students
+// Parameters: email, name, status, metadata
+// Method: POST
+// Output: JSON Encoded Object of the saved student, else error message.
+func CreateStudent(w http.ResponseWriter, r *http.Request) {
+	createProfile(studentProfiles, w, r)
+}
+
+// ListStudents: List the profiles of all students by email
+// URL : /students
+// Parameters: status
+// Method: GET
+// Output: JSON Encoded Array of students, else error message.
+func ListStudents(w http.ResponseWriter, r *http.Request) {
+	listProfiles(studentProfiles, w, r)
+}
+
+// GetStudent: Retrieve the profile of a student
+// URL : /students/{student}
+// Parameters: student (email or id)
+// Method: GET
+// Output: JSON Encoded Object of the student, else error message.
+func GetStudent(w http.ResponseWriter, r *http.Request) {
+	getProfileResponse(studentProfiles, mux.Vars(r)["student"], w)
+}
+
+// UpdateStudent: Replace the name, status & metadata of a student. Emails cannot be changed
+// URL : /students/{student}
+// Parameters: name, status, metadata
+// Method: PUT
+// Output: JSON Encoded Object of the updated student, else error message.
+func UpdateStudent(w http.ResponseWriter, r *http.Request) {
+	updateProfile(studentProfiles, mux.Vars(r)["student"], w, r)
+}
+
+// DeleteStudent: Delete the profile of a student who is not registered, suspended, in a class, linked to a guardian or notified, along with their preferences & contact
+// URL : /students/{student}
+// Parameters: student (email or id)
+// Method: DELETE
+// Output: No content if successful, else error message.
+func DeleteStudent(w http.ResponseWriter, r *http.Request) {
+	deleteProfile(studentProfiles, mux.Vars(r)["student"], w)
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Profile Handlers
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Profile] Save a new teacher or student, refusing an email that already has a profile.
+func createProfile(kind profileKind, w http.ResponseWriter, r *http.Request) {
+	var requestBody model.ProfileBody
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	requestBody, err = validateProfile(kind, requestBody)
+	if err == nil && len(requestBody.Email) == 0 {
+		err = errors.New("Invalid " + kind.Noun + " email format.")
+	}
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	_, err = getProfile(db, kind, requestBody.Email)
+	if err == nil {
+		ErrorResponse(strings.Title(kind.Noun)+" already exists.", w, http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		ErrorResponse("Failed to save "+kind.Noun+".", w, http.StatusNotFound)
+		return
+	}
+
+	now := time.Now().UTC()
+	profile := model.Profile{Email: requestBody.Email, Name: requestBody.Name, Status: requestBody.Status, Metadata: requestBody.Metadata, CreatedAt: now, UpdatedAt: now}
+	metadata, err := json.Marshal(profile.Metadata)
+	if err == nil {
+		var result sql.Result
+		result, err = db.Exec("INSERT INTO "+kind.Table+"(email, name, status, metadata, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+			profile.Email, profile.Name, profile.Status, string(metadata), now, now)
+		if err == nil {
+			profile.Id, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		ErrorResponse("Failed to save "+kind.Noun+".", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
+
+// @Desc: [Profile] List every teacher or student, optionally only those with the given status.
+func listProfiles(kind profileKind, w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if len(status) > 0 && !isValidProfileStatus(status) {
+		ErrorResponse("Invalid status, expected active or inactive.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+profileColumns+" FROM "+kind.Table+" WHERE ? = '' OR status = ? ORDER BY email", status, status)
+	if err != nil {
+		ErrorResponse("Failed to retrieve "+kind.Noun+"s.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	profiles := make([]model.Profile, 0)
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			ErrorResponse("Failed to retrieve "+kind.Noun+"s.", w, http.StatusNotFound)
+			return
+		}
+		profiles = append(profiles, profile)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// @Desc: [Profile] Respond with a single teacher or student.
+func getProfileResponse(kind profileKind, key string, w http.ResponseWriter) {
+	if !validProfileKey(key) {
+		ErrorResponse("Invalid "+kind.Noun+" email or id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	profile, err := getProfile(db, kind, key)
+	if err != nil {
+		profileNotFoundResponse(kind, err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// @Desc: [Profile] Replace the name, status & metadata of a teacher or student.
+func updateProfile(kind profileKind, key string, w http.ResponseWriter, r *http.Request) {
+	var requestBody model.ProfileBody
+
+	if !validProfileKey(key) {
+		ErrorResponse("Invalid "+kind.Noun+" email or id.", w, http.StatusBadRequest)
+		return
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	requestBody, err = validateProfile(kind, requestBody)
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	profile, err := getProfile(db, kind, key)
+	if err != nil {
+		profileNotFoundResponse(kind, err, w)
+		return
+	}
+	// Emails key every other table, so they stay as they are
+	if len(requestBody.Email) > 0 && requestBody.Email != profile.Email {
+		ErrorResponse("Email cannot be changed.", w, http.StatusBadRequest)
+		return
+	}
+
+	profile.Name, profile.Status, profile.Metadata = requestBody.Name, requestBody.Status, requestBody.Metadata
+	profile.UpdatedAt = time.Now().UTC()
+	metadata, err := json.Marshal(profile.Metadata)
+	if err == nil {
+		_, err = db.Exec("UPDATE "+kind.Table+" SET name = ?, status = ?, metadata = ?, updated_at = ? WHERE id = ?",
+			profile.Name, profile.Status, string(metadata), profile.UpdatedAt, profile.Id)
+	}
+	if err != nil {
+		ErrorResponse("Failed to save "+kind.Noun+".", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// @Desc: [Profile] Delete a teacher or student, as long as no relationship references them any more.
+func deleteProfile(kind profileKind, key string, w http.ResponseWriter) {
+	if !validProfileKey(key) {
+		ErrorResponse("Invalid "+kind.Noun+" email or id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	profile, err := getProfile(db, kind, key)
+	if err != nil {
+		profileNotFoundResponse(kind, err, w)
+		return
+	}
+
+	err = deleteProfileRows(db, kind, profile)
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		ErrorResponse(requestErr.Message, w, requestErr.Status)
+		return
+	}
+	// Deleted by another request in the meantime
+	if err == sql.ErrNoRows {
+		profileNotFoundResponse(kind, err, w)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to delete "+kind.Noun+".", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Profile] Trim the profile, defaulting its status to active, and check its name, status, metadata & email if given.
+func validateProfile(kind profileKind, profile model.ProfileBody) (model.ProfileBody, error) {
+	profile.Email = strings.TrimSpace(profile.Email)
+	profile.Name = strings.TrimSpace(profile.Name)
+	if len(profile.Status) == 0 {
+		profile.Status = profileStatusActive
+	}
+	if profile.Metadata == nil {
+		profile.Metadata = make(map[string]string)
+	}
+
+	if len(profile.Email) > 0 && !isMentionEmail(profile.Email) {
+		return profile, errors.New("Invalid " + kind.Noun + " email format.")
+	}
+	if len(profile.Name) == 0 || len(profile.Name) > maxProfileNameLength {
+		return profile, errors.New("Invalid " + kind.Noun + " name.")
+	}
+	if !isValidProfileStatus(profile.Status) {
+		return profile, errors.New("Invalid status, expected active or inactive.")
+	}
+	if len(profile.Metadata) > maxProfileMetadata {
+		return profile, errors.New("Too much metadata, at most " + strconv.Itoa(maxProfileMetadata) + " entries.")
+	}
+	for key, value := range profile.Metadata {
+		if len(strings.TrimSpace(key)) == 0 || len(key) > maxProfileMetadataKey || len(value) > maxProfileMetadataLength {
+			return profile, errors.New("Invalid metadata: " + key + ".")
+		}
+	}
+	return profile, nil
+}
+
+// @Desc: [Profile] Delete a teacher or student together with their own settings, refusing with a requestError while a relationship references them.
+// The references are read with locks held until the profile is deleted, so that none can be added in between.
+func deleteProfileRows(db *sql.DB, kind profileKind, profile model.Profile) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM "+kind.Table+" WHERE id = ? FOR UPDATE", profile.Id).Scan(&id); err != nil {
+		return err
+	}
+	for _, table := range kind.References {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+kind.Noun+" = ? FOR UPDATE", profile.Email).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return &requestError{strings.Title(kind.Noun) + " still has registrations or notifications, set their status to inactive instead.", http.StatusConflict}
+		}
+	}
+
+	for _, table := range kind.Owned {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+kind.Noun+" = ?", profile.Email); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM "+kind.Table+" WHERE id = ?", profile.Id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// @Desc: [Profile] Profiles are looked up by email, or by id when all digits.
+func validProfileKey(key string) bool {
+	if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return true
+	}
+	return isMentionEmail(key)
+}
+
+// @Desc: [Profile] A teacher or student by email or id.
+func getProfile(db *sql.DB, kind profileKind, key string) (model.Profile, error) {
+	column := "email"
+	if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+		column = "id"
+	}
+	return scanProfile(db.QueryRow("SELECT "+profileColumns+" FROM "+kind.Table+" WHERE "+column+" = ?", key))
+}
+
+// @Desc: [Profile] Scans a Teacher or Student row selected with profileColumns.
+func scanProfile(row rowScanner) (model.Profile, error) {
+	var profile model.Profile
+	var metadata sql.NullString
+	err := row.Scan(&profile.Id, &profile.Email, &profile.Name, &profile.Status, &metadata, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return profile, err
+	}
+	if metadata.Valid {
+		err = json.Unmarshal([]byte(metadata.String), &profile.Metadata)
+	}
+	if profile.Metadata == nil {
+		profile.Metadata = make(map[string]string)
+	}
+	return profile, err
+}
+
+// @Desc: [Profile] Create an active profile, named after their email, for each teacher or student that has none yet, so that relationships can reference them.
+func ensureProfiles(db *sql.DB, kind profileKind, emails ...string) error {
+	now := time.Now().UTC()
+	for _, email := range emails {
+		name := email
+		if strings.Contains(email, "@") {
+			name = studentDisplayName(email)
+		}
+		_, err := db.Exec("INSERT INTO "+kind.Table+`(email, name, status, created_at, updated_at) SELECT ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM `+kind.Table+" WHERE email = ?)", email, name, profileStatusActive, now, now, email)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// @Desc: [Profile] The names of the students that have a profile, by email.
+func studentNames(db *sql.DB, students []string) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(students) == 0 {
+		return names, nil
+	}
+
+	args := make([]any, len(students))
+	for i, student := range students {
+		args[i] = student
+	}
+	rows, err := db.Query("SELECT email, name FROM Student WHERE email IN (?"+strings.Repeat(", ?", len(students)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email, name string
+		if err := rows.Scan(&email, &name); err != nil {
+			return nil, err
+		}
+		names[email] = name
+	}
+	return names, rows.Err()
+}
+
+// @Desc: [Profile] Whether the teacher may send notifications, which teachers without a profile may.
+func teacherActive(db *sql.DB, teacher string) (bool, error) {
+	var status string
+	err := db.QueryRow("SELECT status FROM Teacher WHERE email = ?", teacher).Scan(&status)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return status == profileStatusActive, err
+}
+
+// @Desc: [Profile] Whether the status is one a teacher or student can have.
+func isValidProfileStatus(status string) bool {
+	return status == profileStatusActive || status == profileStatusInactive
+}
+
+// @Desc: [Profile] Respond to a profile lookup failing, telling a missing profile apart from a database failure.
+func profileNotFoundResponse(kind profileKind, err error, w http.ResponseWriter) {
+	if err == sql.ErrNoRows {
+		ErrorResponse(strings.Title(kind.Noun)+" not found.", w, http.StatusNotFound)
+		return
+	}
+	ErrorResponse("Failed to retrieve "+kind.Noun+".", w, http.StatusNotFound)
+}
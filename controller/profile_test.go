@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Teachers & Students
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Profiles should be refused without an email or name, with an unknown status or with too much metadata.
+func TestCreateStudentInvalid(t *testing.T) {
+	bodies := map[string]string{
+		`{"name": "Jon Tan"}`:                                                     "Invalid student email format.",
+		`{"email": "studentjon@gmail.com", "name": "  "}`:                         "Invalid student name.",
+		`{"email": "studentjon@gmail.com", "name": "Jon", "status": "left"}`:      "Invalid status, expected active or inactive.",
+		`{"email": "studentjon@gmail.com", "name": "Jon", "metadata": {"": "x"}}`: "Invalid metadata: .",
+	}
+	for body, message := range bodies {
+		request, _ := http.NewRequest("POST", "/api/students", bytes.NewBufferString(body))
+		response := httptest.NewRecorder()
+		CreateStudent(response, request)
+		assert.Equal(t, http.StatusBadRequest, response.Code, body)
+		assert.JSONEq(t, `{"message": "`+message+`"}`, response.Body.String())
+	}
+	log.Println("SUCCESS: TestCreateStudentInvalid")
+}
+
+// @Desc: [FAIL] Profiles should only be looked up by an email or a numeric id.
+func TestGetTeacherInvalidKey(t *testing.T) {
+	assert.True(t, validProfileKey("42"))
+	assert.True(t, validProfileKey("teacherken@gmail.com"))
+
+	request, _ := http.NewRequest("GET", "/api/teachers/ken", nil)
+	request = mux.SetURLVars(request, map[string]string{"teacher": "ken"})
+	response := httptest.NewRecorder()
+	GetTeacher(response, request)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"message": "Invalid teacher email or id."}`, response.Body.String())
+	log.Println("SUCCESS: TestGetTeacherInvalidKey")
+}
+
+// @Desc: [VALID] Mentions & templates should use the student's profile name, falling back to their display name.
+func TestRenderProfileNames(t *testing.T) {
+	text := "Hi @s1@gmail.com & @s2@gmail.com"
+	mentions, _ := parseMentions(text)
+	names := map[string]string{"s1@gmail.com": "Jon Tan"}
+	assert.Equal(t, "Hi @Jon Tan & @s2", renderMentions(text, mentions, renderMentionsName, names))
+
+	rendered, err := renderTemplate("Dear {{.StudentName}}", "t1@gmail.com", "s1@gmail.com", "Jon Tan", time.Date(2023, 2, 16, 8, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, "Dear Jon Tan", rendered)
+	log.Println("SUCCESS: TestRenderProfileNames")
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                           Token Buckets
+//////////////////////////////////////////////////////////////*/
+
+// A bucket of up to `capacity` tokens, refilled continuously so that a full bucket's worth is added back every period
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(capacity), capacity: float64(capacity), rate: float64(capacity) / period.Seconds(), last: now}
+}
+
+// @Desc: [RateLimit] Add the tokens refilled since the bucket was last used, taking on a new capacity if the limit changed.
+func (b *tokenBucket) refill(capacity int, period time.Duration, now time.Time) {
+	if float64(capacity) != b.capacity {
+		b.capacity = float64(capacity)
+		b.rate = b.capacity / period.Seconds()
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		b.last = now
+	}
+	b.tokens = math.Min(b.tokens, b.capacity)
+}
+
+// @Desc: [RateLimit] How long until the bucket holds `n` tokens, no time if it already does.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// @Desc: [RateLimit] Whole tokens left in the bucket.
+func (b *tokenBucket) remaining() int {
+	return int(math.Max(0, math.Floor(b.tokens)))
+}
+
+// The buckets a teacher's sending is metered with
+type teacherQuota struct {
+	hourly     *tokenBucket
+	daily      *tokenBucket
+	recipients *tokenBucket
+}
+
+// rateLimiter: Per teacher quotas, kept in memory so a restart starts every teacher with a full quota
+type rateLimiter struct {
+	mu     sync.Mutex
+	quotas map[string]*teacherQuota
+}
+
+var notificationLimiter = &rateLimiter{quotas: make(map[string]*teacherQuota)}
+
+// @Desc: [RateLimit] The teacher's refilled quota under the given limits. Must be called with the lock held.
+func (l *rateLimiter) quota(teacher string, limits model.RateLimits, now time.Time) *teacherQuota {
+	quota, ok := l.quotas[teacher]
+	if !ok {
+		quota = &teacherQuota{
+			hourly:     newTokenBucket(limits.NotificationsPerHour, time.Hour, now),
+			daily:      newTokenBucket(limits.NotificationsPerDay, 24*time.Hour, now),
+			recipients: newTokenBucket(limits.RecipientsPerDay, 24*time.Hour, now),
+		}
+		l.quotas[teacher] = quota
+	}
+	quota.hourly.refill(limits.NotificationsPerHour, time.Hour, now)
+	quota.daily.refill(limits.NotificationsPerDay, 24*time.Hour, now)
+	quota.recipients.refill(limits.RecipientsPerDay, 24*time.Hour, now)
+	return quota
+}
+
+// @Desc: [RateLimit] Take one notification out of the teacher's quota. When a limit is exhausted nothing is taken,
+// and the time until the notification could be sent is returned along with the limit that was hit.
+func (l *rateLimiter) reserveNotification(teacher string, limits model.RateLimits, now time.Time) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	quota := l.quota(teacher, limits, now)
+
+	var retryAfter time.Duration
+	var exceeded string
+	checks := []struct {
+		bucket *tokenBucket
+		limit  string
+	}{
+		{quota.hourly, fmt.Sprintf("%d notifications per hour", limits.NotificationsPerHour)},
+		{quota.daily, fmt.Sprintf("%d notifications per day", limits.NotificationsPerDay)},
+		{quota.recipients, fmt.Sprintf("%d recipients per day", limits.RecipientsPerDay)},
+	}
+	for _, check := range checks {
+		if wait := check.bucket.wait(1); wait > retryAfter {
+			retryAfter, exceeded = wait, check.limit
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter, exceeded
+	}
+	quota.hourly.tokens--
+	quota.daily.tokens--
+	return 0, ""
+}
+
+// @Desc: [RateLimit] Give back the notification taken out of the teacher's quota for one that was not sent or scheduled after all.
+func (l *rateLimiter) refundNotification(teacher string, limits model.RateLimits, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	quota := l.quota(teacher, limits, now)
+	quota.hourly.tokens = math.Min(quota.hourly.tokens+1, quota.hourly.capacity)
+	quota.daily.tokens = math.Min(quota.daily.tokens+1, quota.daily.capacity)
+}
+
+// @Desc: [RateLimit] Take the recipients a notification is about to be delivered to out of the teacher's quota. When fewer are left nothing is taken,
+// and the time until there are enough is returned along with the limit. A notification to more recipients than the limit must be refused beforehand.
+func (l *rateLimiter) reserveRecipients(teacher string, limits model.RateLimits, recipients int, now time.Time) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	quota := l.quota(teacher, limits, now)
+	if wait := quota.recipients.wait(float64(recipients)); wait > 0 {
+		return wait, fmt.Sprintf("%d recipients per day", limits.RecipientsPerDay)
+	}
+	quota.recipients.tokens -= float64(recipients)
+	return 0, ""
+}
+
+// @Desc: [RateLimit] Settle the recipients reserved for a delivery with those it was recorded against, giving back the rest when it failed or reached fewer.
+// A delivery reaching more, e.g. students registered in between, puts the quota in debt until it refills.
+func (l *rateLimiter) settleRecipients(teacher string, limits model.RateLimits, reserved int, delivered int, now time.Time) {
+	if reserved == delivered {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	quota := l.quota(teacher, limits, now)
+	quota.recipients.tokens = math.Min(quota.recipients.tokens+float64(reserved-delivered), quota.recipients.capacity)
+}
+
+// @Desc: [RateLimit] What is left of the teacher's quota.
+func (l *rateLimiter) remaining(teacher string, limits model.RateLimits, now time.Time) model.RateLimits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	quota := l.quota(teacher, limits, now)
+	return model.RateLimits{
+		NotificationsPerHour: quota.hourly.remaining(),
+		NotificationsPerDay:  quota.daily.remaining(),
+		RecipientsPerDay:     quota.recipients.remaining(),
+	}
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Rate Limit Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// TeacherRateLimit: Retrieve a teacher's quota, any override of it and what is left of it. Admin only
+// URL : /admin/ratelimits/{teacher}
+// Parameters: teacher (X-Admin-Token header required)
+// Method: GET
+// Output: JSON Encoded Object of the teacher's rate limits, else error message.
+func TeacherRateLimit(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := checkRateLimitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	status, err := getRateLimitStatus(db, teacher)
+	if err != nil {
+		ErrorResponse("Failed to retrieve rate limits.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(status)
+}
+
+// UpdateTeacherRateLimit: Override some or all of a teacher's limits, the others keep their defaults. Admin only
+// URL : /admin/ratelimits/{teacher}
+// Parameters: notifications_per_hour, notifications_per_day, recipients_per_day (X-Admin-Token header required)
+// Method: PUT
+// Output: JSON Encoded Object of the teacher's rate limits, else error message.
+func UpdateTeacherRateLimit(w http.ResponseWriter, r *http.Request) {
+	var override model.RateLimitOverride
+
+	teacher, ok := checkRateLimitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&override)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	for _, limit := range []*int{override.NotificationsPerHour, override.NotificationsPerDay, override.RecipientsPerDay} {
+		if limit != nil && *limit <= 0 {
+			ErrorResponse("Invalid rate limit, expected a positive number.", w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO RateLimitOverride(teacher, notifications_per_hour, notifications_per_day, recipients_per_day) VALUES(?, ?, ?, ?)
+	ON DUPLICATE KEY UPDATE notifications_per_hour = VALUES(notifications_per_hour), notifications_per_day = VALUES(notifications_per_day),
+	recipients_per_day = VALUES(recipients_per_day)`,
+		teacher, override.NotificationsPerHour, override.NotificationsPerDay, override.RecipientsPerDay)
+	if err != nil {
+		ErrorResponse("Failed to save rate limits.", w, http.StatusNotFound)
+		return
+	}
+
+	status, err := getRateLimitStatus(db, teacher)
+	if err != nil {
+		ErrorResponse("Failed to retrieve rate limits.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(status)
+}
+
+// DeleteTeacherRateLimit: Return a teacher to the default limits. Admin only
+// URL : /admin/ratelimits/{teacher}
+// Parameters: teacher (X-Admin-Token header required)
+// Method: DELETE
+// Output: No content if successful, else error message.
+func DeleteTeacherRateLimit(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := checkRateLimitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM RateLimitOverride WHERE teacher = ?", teacher)
+	if err != nil {
+		ErrorResponse("Failed to delete rate limits.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [RateLimit] Writes the matching error response and returns false unless an admin asked about a valid teacher.
+func checkRateLimitRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !isAdminRequest(r) {
+		ErrorResponse("Admin authorisation required.", w, http.StatusForbidden)
+		return "", false
+	}
+	teacher := mux.Vars(r)["teacher"]
+	if !validEmailFormat(teacher) {
+		ErrorResponse("Invalid teacher email format.", w, http.StatusBadRequest)
+		return "", false
+	}
+	return teacher, true
+}
+
+// @Desc: [RateLimit] The limits a teacher sends under, the defaults inside `config.go` unless an admin overrode them.
+func getRateLimits(db *sql.DB, teacher string) (model.RateLimits, *model.RateLimitOverride, error) {
+	limits := model.RateLimits{
+		NotificationsPerHour: config.RateLimitNotificationsPerHour,
+		NotificationsPerDay:  config.RateLimitNotificationsPerDay,
+		RecipientsPerDay:     config.RateLimitRecipientsPerDay,
+	}
+
+	var perHour, perDay, recipientsPerDay sql.NullInt64
+	err := db.QueryRow("SELECT notifications_per_hour, notifications_per_day, recipients_per_day FROM RateLimitOverride WHERE teacher = ?", teacher).
+		Scan(&perHour, &perDay, &recipientsPerDay)
+	if err == sql.ErrNoRows {
+		return limits, nil, nil
+	}
+	if err != nil {
+		return limits, nil, err
+	}
+
+	override := &model.RateLimitOverride{}
+	for _, field := range []struct {
+		value    sql.NullInt64
+		limit    *int
+		override **int
+	}{
+		{perHour, &limits.NotificationsPerHour, &override.NotificationsPerHour},
+		{perDay, &limits.NotificationsPerDay, &override.NotificationsPerDay},
+		{recipientsPerDay, &limits.RecipientsPerDay, &override.RecipientsPerDay},
+	} {
+		if field.value.Valid {
+			*field.limit = int(field.value.Int64)
+			*field.override = field.limit
+		}
+	}
+	return limits, override, nil
+}
+
+// @Desc: [RateLimit] The teacher's limits along with what is left of them.
+func getRateLimitStatus(db *sql.DB, teacher string) (model.RateLimitStatus, error) {
+	limits, override, err := getRateLimits(db, teacher)
+	if err != nil {
+		return model.RateLimitStatus{}, err
+	}
+	return model.RateLimitStatus{
+		Teacher:   teacher,
+		Limits:    limits,
+		Override:  override,
+		Remaining: notificationLimiter.remaining(teacher, limits, time.Now()),
+	}, nil
+}
+
+// @Desc: [RateLimit] Refuse a request over the teacher's quota, telling them when to retry.
+func rateLimitedResponse(retryAfter time.Duration, exceeded string, w http.ResponseWriter) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	ErrorResponse("Rate limit exceeded: "+exceeded+".", w, http.StatusTooManyRequests)
+}
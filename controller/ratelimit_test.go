@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Rate Limits
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] A teacher should be refused once their hourly quota is used up, told when to retry, and let through again once it refills.
+func TestReserveNotificationRefills(t *testing.T) {
+	limiter := &rateLimiter{quotas: make(map[string]*teacherQuota)}
+	limits := model.RateLimits{NotificationsPerHour: 2, NotificationsPerDay: 10, RecipientsPerDay: 100}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		retryAfter, _ := limiter.reserveNotification("t1@gmail.com", limits, now)
+		assert.Zero(t, retryAfter, "Notifications within the quota should be let through.")
+	}
+	retryAfter, exceeded := limiter.reserveNotification("t1@gmail.com", limits, now)
+	assert.Equal(t, 30*time.Minute, retryAfter, "One notification should refill every half hour.")
+	assert.Equal(t, "2 notifications per hour", exceeded)
+
+	retryAfter, _ = limiter.reserveNotification("t2@gmail.com", limits, now)
+	assert.Zero(t, retryAfter, "Quotas should be kept per teacher.")
+
+	retryAfter, _ = limiter.reserveNotification("t1@gmail.com", limits, now.Add(30*time.Minute))
+	assert.Zero(t, retryAfter, "A refilled notification should be let through.")
+	assert.Equal(t, 7, limiter.remaining("t1@gmail.com", limits, now.Add(30*time.Minute)).NotificationsPerDay)
+	log.Println("SUCCESS: TestReserveNotificationRefills")
+}
+
+// @Desc: [VALID] Recipients should be reserved up front, refused when fewer are left, and given back for those a delivery did not reach.
+func TestReserveRecipients(t *testing.T) {
+	limiter := &rateLimiter{quotas: make(map[string]*teacherQuota)}
+	limits := model.RateLimits{NotificationsPerHour: 10, NotificationsPerDay: 10, RecipientsPerDay: 24}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	retryAfter, _ := limiter.reserveRecipients("t1@gmail.com", limits, 20, now)
+	assert.Zero(t, retryAfter, "Recipients within the quota should be let through.")
+	retryAfter, exceeded := limiter.reserveRecipients("t1@gmail.com", limits, 5, now)
+	assert.Equal(t, time.Hour, retryAfter, "One recipient should refill every hour.")
+	assert.Equal(t, "24 recipients per day", exceeded)
+	assert.Equal(t, 4, limiter.remaining("t1@gmail.com", limits, now).RecipientsPerDay, "A refused reservation should take nothing.")
+
+	limiter.settleRecipients("t1@gmail.com", limits, 20, 15, now)
+	assert.Equal(t, 9, limiter.remaining("t1@gmail.com", limits, now).RecipientsPerDay, "Recipients not reached should be given back.")
+	limiter.settleRecipients("t1@gmail.com", limits, 0, 12, now)
+	retryAfter, _ = limiter.reserveNotification("t1@gmail.com", limits, now)
+	assert.Equal(t, 4*time.Hour, retryAfter, "Recipients reached over the reservation should put the quota in debt.")
+	log.Println("SUCCESS: TestReserveRecipients")
+}
+
+// @Desc: [VALID] A notification that was not sent after all should be given back, without going over the quota.
+func TestRefundNotification(t *testing.T) {
+	limiter := &rateLimiter{quotas: make(map[string]*teacherQuota)}
+	limits := model.RateLimits{NotificationsPerHour: 2, NotificationsPerDay: 10, RecipientsPerDay: 100}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	retryAfter, _ := limiter.reserveNotification("t1@gmail.com", limits, now)
+	assert.Zero(t, retryAfter)
+	limiter.refundNotification("t1@gmail.com", limits, now)
+	limiter.refundNotification("t1@gmail.com", limits, now)
+	remaining := limiter.remaining("t1@gmail.com", limits, now)
+	assert.Equal(t, 2, remaining.NotificationsPerHour)
+	assert.Equal(t, 10, remaining.NotificationsPerDay)
+	log.Println("SUCCESS: TestRefundNotification")
+}
+
+// @Desc: [FAIL] Rate limits should only be overridden by an admin, with positive limits.
+func TestUpdateTeacherRateLimitInvalid(t *testing.T) {
+	request, _ := http.NewRequest("PUT", "/api/admin/ratelimits/t1@gmail.com", bytes.NewBufferString(`{"notifications_per_hour": 60}`))
+	request = mux.SetURLVars(request, map[string]string{"teacher": "t1@gmail.com"})
+	response := httptest.NewRecorder()
+	UpdateTeacherRateLimit(response, request)
+	assert.Equal(t, http.StatusForbidden, response.Code, "Overrides without the admin token should be refused.")
+
+	request, _ = http.NewRequest("PUT", "/api/admin/ratelimits/t1@gmail.com", bytes.NewBufferString(`{"notifications_per_hour": 0}`))
+	request.Header.Set("X-Admin-Token", config.AdminToken)
+	request = mux.SetURLVars(request, map[string]string{"teacher": "t1@gmail.com"})
+	response = httptest.NewRecorder()
+	UpdateTeacherRateLimit(response, request)
+	assert.Equal(t, http.StatusBadRequest, response.Code, "Limits should be positive.")
+	assert.JSONEq(t, `{"message": "Invalid rate limit, expected a positive number."}`, response.Body.String())
+	log.Println("SUCCESS: TestUpdateTeacherRateLimitInvalid")
+}
@@ -0,0 +1,431 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const (
+	streamEventNotificationUpdated  = "notification_updated"
+	streamEventNotificationRecalled = "notification_recalled"
+)
+
+/*///////////////////////////////////////////////////////////////
+                      Edit & Recall Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// EditNotification: Correct the text of a sent notification within the recall window, keeping the previous text in its history
+// URL : /notifications/{id}/edit
+// Parameters: teacher, notification
+// Method: POST
+// Output: JSON Encoded Object of the edited notification, else error message.
+func EditNotification(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.EditNotificationBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(requestBody.Notification)) == 0 {
+		ErrorResponse("Empty teacher or notification format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	sent, ok := checkRecallableNotification(db, id, requestBody.Teacher, w)
+	if !ok {
+		return
+	}
+	// Recipients may have received any of the variants, which an edit of the notification text would not correct
+	variants, err := getVariants(db, id)
+	if err != nil {
+		ErrorResponse("Failed to edit notification.", w, http.StatusNotFound)
+		return
+	}
+	if len(variants) > 0 {
+		ErrorResponse("Notifications with language variants cannot be edited, recall it instead.", w, http.StatusConflict)
+		return
+	}
+
+	original := requestBody.Notification
+	text, moderation := moderateContent(requestBody.Notification)
+	if moderation.Outcome == moderationBlock {
+		moderationBlockedResponse(db, sent.Teacher, original, moderation, w)
+		return
+	}
+	requestBody.Notification = text
+
+	// A notification sent from a template is edited as a template, and rendered again for each recipient
+	if sent.TemplateId != nil {
+		if _, err := parseTemplate(requestBody.Notification); err != nil {
+			ErrorResponse(err.Error(), w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	recipients, err := queryColumn(db, "SELECT student FROM NotificationRecipient WHERE notification_id = ? ORDER BY student", id)
+	if err != nil {
+		ErrorResponse("Failed to edit notification.", w, http.StatusNotFound)
+		return
+	}
+
+	pushed, err := getPushedRecipients(db, id)
+	if err != nil {
+		ErrorResponse("Failed to edit notification.", w, http.StatusNotFound)
+		return
+	}
+
+	messages, err := editNotification(db, sent, requestBody.Notification, recipients)
+	if err != nil {
+		ErrorResponse("Failed to edit notification.", w, http.StatusNotFound)
+		return
+	}
+
+	edited, err := getNotification(db, id)
+	if err == nil {
+		err = recordModeration(db, sent.Teacher, &sent.Id, nil, original, moderation)
+	}
+	if err != nil {
+		ErrorResponse("Failed to edit notification.", w, http.StatusNotFound)
+		return
+	}
+
+	for _, student := range recipients {
+		text := edited.Notification
+		if message, ok := messages[student]; ok {
+			text = message
+		}
+		text, html := renderNotificationBody(text, edited.Format)
+		textMentions, _ := parseMentions(text)
+		notificationHub.Publish(studentTopic(student), streamEventNotificationUpdated, model.InboxNotification{
+			Id:           edited.Id,
+			Teacher:      edited.Teacher,
+			Notification: text,
+			HTML:         html,
+			Mentions:     mentionSpans(text, textMentions),
+			Priority:     edited.Priority,
+			RequiresAck:  edited.RequiresAck,
+			SentAt:       edited.SentAt,
+		})
+	}
+	notificationHub.Publish(classroomTopic(edited.Teacher), streamEventNotificationUpdated, edited)
+	publishDeliveryUpdate(edited, nil)
+	pushCorrection(db, edited, pushed, "Updated notification from "+edited.Teacher, func(student string) string {
+		if message, ok := messages[student]; ok {
+			return message
+		}
+		return edited.Notification
+	})
+	if err := currentSearchIndex().Index(db, edited); err != nil {
+		log.Printf("Failed to index notification %d for search: %v", edited.Id, err)
+	}
+
+	// Only the teacher is told what the content filters found
+	edited.Moderation = moderationSummary(moderation)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(edited)
+}
+
+// RecallNotification: Withdraw a sent notification from its recipients' inboxes within the recall window
+// URL : /notifications/{id}/recall
+// Parameters: teacher
+// Method: POST
+// Output: No content if successful, else error message.
+func RecallNotification(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.CancelNotificationBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	sent, ok := checkRecallableNotification(db, id, requestBody.Teacher, w)
+	if !ok {
+		return
+	}
+
+	// Those waiting for their digest are looked up before it is taken out of the digest
+	pushed, err := getPushedRecipients(db, id)
+	if err != nil {
+		ErrorResponse("Failed to recall notification.", w, http.StatusNotFound)
+		return
+	}
+
+	result, err := db.Exec("UPDATE NotificationMessage SET status = ? WHERE id = ? AND status = ?", notificationStatusRecalled, id, notificationStatusSent)
+	if err != nil {
+		ErrorResponse("Failed to recall notification.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Notification has been recalled.", w, http.StatusConflict)
+		return
+	}
+
+	// Not yet summarised into a digest, so it never will be
+	_, err = db.Exec("DELETE FROM DigestEntry WHERE notification_id = ? AND digest_id IS NULL", id)
+	if err != nil {
+		ErrorResponse("Failed to recall notification.", w, http.StatusNotFound)
+		return
+	}
+
+	recipients, err := queryColumn(db, "SELECT student FROM NotificationRecipient WHERE notification_id = ?", id)
+	if err == nil {
+		for _, student := range recipients {
+			notificationHub.Publish(studentTopic(student), streamEventNotificationRecalled, model.InboxNotification{Id: id, Teacher: sent.Teacher, SentAt: sent.SentAt})
+		}
+	}
+	sent.Status = notificationStatusRecalled
+	notificationHub.Publish(classroomTopic(sent.Teacher), streamEventNotificationRecalled, sent)
+	publishDeliveryUpdate(sent, nil)
+	pushCorrection(db, sent, pushed, "Notification recalled by "+sent.Teacher, func(string) string {
+		return "Please disregard the notification from " + sent.Teacher + ", it has been recalled."
+	})
+	if err := currentSearchIndex().Remove(db, id); err != nil {
+		log.Printf("Failed to remove notification %d from search: %v", id, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// NotificationHistory: List the previous versions of a teacher's edited notification, oldest first
+// URL : /notifications/{id}/history
+// Parameters: id, teacher
+// Method: GET
+// Output: JSON Encoded Array of previous versions, else error message.
+func NotificationHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+	teacher := r.URL.Query().Get("teacher")
+	if len(teacher) == 0 {
+		ErrorResponse("No teacher specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	notification, err := getNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && notification.Teacher != teacher) {
+		ErrorResponse("Notification not found.", w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return
+	}
+
+	rows, err := db.Query("SELECT version, message, replaced_at FROM NotificationVersion WHERE notification_id = ? ORDER BY version", id)
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification history.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	versions := make([]model.NotificationVersion, 0)
+	for rows.Next() {
+		var version model.NotificationVersion
+		if err := rows.Scan(&version.Version, &version.Notification, &version.ReplacedAt); err != nil {
+			ErrorResponse("Failed to retrieve notification history.", w, http.StatusNotFound)
+			return
+		}
+		versions = append(versions, version)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(versions)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Recall] Save the current text as a version in the history, then replace it, re-rendering the text of each recipient of a templated notification.
+// Returns the re-rendered text of each recipient, if any.
+func editNotification(db *sql.DB, sent model.ScheduledNotification, text string, recipients []string) (map[string]string, error) {
+	messages := make(map[string]string)
+	if sent.TemplateId != nil {
+		names, err := studentNames(db, recipients)
+		if err != nil {
+			return nil, err
+		}
+		for _, student := range recipients {
+			rendered, err := renderTemplate(text, sent.Teacher, student, names[student], sent.SendAt)
+			if err != nil {
+				return nil, err
+			}
+			messages[student] = rendered
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`INSERT INTO NotificationVersion(notification_id, version, message, replaced_at)
+	SELECT ?, COUNT(*) + 1, ?, ? FROM NotificationVersion WHERE notification_id = ?`, sent.Id, sent.Notification, now, sent.Id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE NotificationMessage SET message = ?, edited_at = ? WHERE id = ?", text, now, sent.Id)
+	if err != nil {
+		return nil, err
+	}
+	for student, message := range messages {
+		_, err = tx.Exec("UPDATE NotificationRecipient SET message = ? WHERE notification_id = ? AND student = ?", message, sent.Id, student)
+		if err != nil {
+			return nil, err
+		}
+		// Guardians still held back are later sent the text kept against them
+		_, err = tx.Exec("UPDATE NotificationGuardian SET message = ? WHERE notification_id = ? AND student = ?", message, sent.Id, student)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, tx.Commit()
+}
+
+// Who a notification was already pushed to on their channels: students not held back or waiting for their digest, and guardians by the students they were sent it about
+type pushedRecipients struct {
+	Students  []string
+	Guardians []model.GuardianRecipient
+}
+
+// @Desc: [Recall] The students & guardians already pushed the notification, who are pushed its edit or recall. The others are sent the edited text or nothing at all.
+func getPushedRecipients(db *sql.DB, id int64) (pushedRecipients, error) {
+	var pushed pushedRecipients
+	students, err := queryColumn(db, `SELECT student FROM NotificationRecipient AS Recipient WHERE notification_id = ? AND push_held = 0
+	AND NOT EXISTS (SELECT 1 FROM DigestEntry WHERE notification_id = Recipient.notification_id AND student = Recipient.student AND digest_id IS NULL)
+	ORDER BY student`, id)
+	if err != nil {
+		return pushed, err
+	}
+	pushed.Students = students
+
+	rows, err := db.Query("SELECT guardian_id, student FROM NotificationGuardian WHERE notification_id = ? AND push_held = 0 ORDER BY guardian_id, student", id)
+	if err != nil {
+		return pushed, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var guardian int64
+		var student string
+		if err := rows.Scan(&guardian, &student); err != nil {
+			return pushed, err
+		}
+		if last := len(pushed.Guardians) - 1; last >= 0 && pushed.Guardians[last].Id == guardian {
+			pushed.Guardians[last].Students = append(pushed.Guardians[last].Students, student)
+			continue
+		}
+		pushed.Guardians = append(pushed.Guardians, model.GuardianRecipient{Id: guardian, Students: []string{student}})
+	}
+	return pushed, rows.Err()
+}
+
+// @Desc: [Recall] Push the edit or recall of a notification on the channels it already went out on, with each student's text and to their guardians,
+// one message per guardian & distinct text. Failures are logged, as the change is already in every inbox.
+func pushCorrection(db *sql.DB, notification model.ScheduledNotification, pushed pushedRecipients, subject string, textFor func(student string) string) {
+	for _, student := range pushed.Students {
+		preferences, err := getStudentPreferences(db, student)
+		if err != nil {
+			log.Printf("Failed to push notification %d correction to %s: %v", notification.Id, student, err)
+			continue
+		}
+		text, html := renderNotificationBody(textFor(student), notification.Format)
+		pushNotification(db, channelMessage{NotificationId: notification.Id, Teacher: notification.Teacher, Student: student, Subject: subject, Text: text, HTML: html}, preferences)
+	}
+
+	for _, recipient := range pushed.Guardians {
+		guardian, err := getGuardian(db, recipient.Id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to push notification %d correction to guardian %d: %v", notification.Id, recipient.Id, err)
+			continue
+		}
+		var texts []string
+		wards := make(map[string][]string)
+		for _, student := range recipient.Students {
+			text := textFor(student)
+			if _, ok := wards[text]; !ok {
+				texts = append(texts, text)
+			}
+			wards[text] = append(wards[text], student)
+		}
+		for _, text := range texts {
+			body, html := renderNotificationBody(text, notification.Format)
+			pushNotification(db, channelMessage{NotificationId: notification.Id, Teacher: notification.Teacher, Guardian: &guardian, Wards: wards[text],
+				Subject: subject, Text: body, HTML: html}, model.StudentPreferences{})
+		}
+	}
+}
+
+// @Desc: [Recall] Writes the matching error response and returns false unless the notification exists, belongs to the teacher, was sent and is still within the recall window.
+func checkRecallableNotification(db *sql.DB, id int64, teacher string, w http.ResponseWriter) (model.ScheduledNotification, bool) {
+	notification, err := getNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && notification.Teacher != teacher) {
+		ErrorResponse("Notification not found.", w, http.StatusNotFound)
+		return notification, false
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return notification, false
+	}
+	switch {
+	case notification.Status == notificationStatusRecalled:
+		ErrorResponse("Notification has been recalled.", w, http.StatusConflict)
+		return notification, false
+	case notification.Status != notificationStatusSent || notification.SentAt == nil:
+		ErrorResponse("Notification has not been sent.", w, http.StatusConflict)
+		return notification, false
+	case !withinRecallWindow(*notification.SentAt, time.Now()):
+		ErrorResponse("Recall window has passed.", w, http.StatusConflict)
+		return notification, false
+	}
+	return notification, true
+}
+
+// @Desc: [Recall] Whether a notification sent at `sentAt` can still be edited or recalled at `now`.
+func withinRecallWindow(sentAt time.Time, now time.Time) bool {
+	return now.Sub(sentAt) <= config.RecallWindow
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Edit & Recall
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Editing with an invalid id, no teacher or an empty notification, which should fail with HTTP code 400.
+func TestEditNotificationInvalid(t *testing.T) {
+	cases := []struct {
+		id       string
+		body     string
+		expected string
+	}{
+		{"abc", `{"teacher":"t1@gmail.com","notification":"Hello"}`, `{"message":"Invalid notification id."}`},
+		{"1", `{"notification":"Hello"}`, `{"message":"Invalid request body format."}`},
+		{"1", `{"teacher":"t1@gmail.com","notification":"  "}`, `{"message":"Empty teacher or notification format."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("POST", "/api/notifications/"+c.id+"/edit", bytes.NewBuffer([]byte(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": c.id})
+
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(EditNotification)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestEditNotificationInvalid")
+}
+
+// @Desc: [FAIL] Recalling without specifying the teacher, which should fail with HTTP code 400.
+func TestRecallNotificationNoTeacher(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/notifications/1/recall", bytes.NewBuffer([]byte(`{}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RecallNotification)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid request body format."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRecallNotificationNoTeacher")
+}
+
+// @Desc: [VALID] A notification can be edited or recalled up to the end of the recall window, but not after.
+func TestWithinRecallWindow(t *testing.T) {
+	sentAt := time.Date(2023, 2, 15, 3, 0, 0, 0, time.UTC)
+	assert.True(t, withinRecallWindow(sentAt, sentAt.Add(time.Minute)), "A minute later should be within the window.")
+	assert.True(t, withinRecallWindow(sentAt, sentAt.Add(config.RecallWindow)), "The end of the window should be within it.")
+	assert.False(t, withinRecallWindow(sentAt, sentAt.Add(config.RecallWindow+time.Second)), "After the window should not be within it.")
+	log.Println("SUCCESS: TestWithinRecallWindow")
+}
@@ -0,0 +1,468 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Columns selected whenever a RecurringNotification row is read back through scanRecurringNotification
+const recurringColumns = "id, teacher, message, schedule, timezone, status, next_run_at, last_run_at, created_at"
+
+const (
+	recurringStatusActive = "active"
+	recurringStatusPaused = "paused"
+)
+
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 50
+)
+
+/*///////////////////////////////////////////////////////////////
+                  Recurring Notification Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// CreateRecurringNotification: Define a notification that is sent repeatedly on a cron schedule
+// URL : /recurringnotifications
+// Parameters: teacher, notification, schedule, timezone
+// Method: POST
+// Output: JSON Encoded Object of the recurring notification with its first run, else error message.
+func CreateRecurringNotification(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.CreateRecurringNotificationBody
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if len(requestBody.Teacher) == 0 || len(strings.TrimSpace(requestBody.Notification)) == 0 {
+		ErrorResponse("Empty teacher or notification format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if len(requestBody.Timezone) == 0 {
+		requestBody.Timezone = "UTC"
+	}
+	schedule, location, err := parseRecurringSchedule(requestBody.Schedule, requestBody.Timezone)
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	original := requestBody.Notification
+	notification, moderation := moderateContent(requestBody.Notification)
+	if moderation.Outcome == moderationBlock {
+		moderationBlockedResponse(db, requestBody.Teacher, original, moderation, w)
+		return
+	}
+	requestBody.Notification = notification
+
+	active, err := teacherActive(db, requestBody.Teacher)
+	if err != nil {
+		ErrorResponse("Failed to retrieve teacher.", w, http.StatusNotFound)
+		return
+	}
+	if !active {
+		ErrorResponse("Teacher is inactive.", w, http.StatusForbidden)
+		return
+	}
+
+	mentions, emails, _ := extractMentions(requestBody.Notification)
+	err = registerMentions(db, requestBody.Teacher, emails)
+	if err != nil {
+		ErrorResponse("Failed to register student emails for notifications", w, http.StatusNotFound)
+		return
+	}
+
+	recurring := model.RecurringNotification{
+		Teacher:      requestBody.Teacher,
+		Notification: requestBody.Notification,
+		Mentions:     mentionSpans(requestBody.Notification, mentions),
+		Schedule:     requestBody.Schedule,
+		Timezone:     requestBody.Timezone,
+		Status:       recurringStatusActive,
+		NextRunAt:    nextRecurringRun(schedule, location, time.Now()),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	result, err := db.Exec("INSERT INTO RecurringNotification(teacher, message, schedule, timezone, status, next_run_at, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		recurring.Teacher, recurring.Notification, recurring.Schedule, recurring.Timezone, recurring.Status, recurring.NextRunAt, recurring.CreatedAt)
+	if err != nil {
+		ErrorResponse("Failed to create recurring notification.", w, http.StatusNotFound)
+		return
+	}
+	recurring.Id, err = result.LastInsertId()
+	if err != nil {
+		ErrorResponse("Failed to create recurring notification.", w, http.StatusNotFound)
+		return
+	}
+	if err := recordModeration(db, recurring.Teacher, nil, &recurring.Id, original, moderation); err != nil {
+		ErrorResponse("Failed to create recurring notification.", w, http.StatusNotFound)
+		return
+	}
+	recurring.Moderation = moderationSummary(moderation)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(recurring)
+}
+
+// ListRecurringNotifications: List all recurring notifications defined by a teacher
+// URL : /recurringnotifications
+// Parameters: teacher
+// Method: GET
+// Output: JSON Encoded Array of recurring notifications, else error message.
+func ListRecurringNotifications(w http.ResponseWriter, r *http.Request) {
+	teacher := r.URL.Query().Get("teacher")
+	if len(teacher) == 0 {
+		ErrorResponse("No teacher specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+recurringColumns+" FROM RecurringNotification WHERE teacher = ? ORDER BY id", teacher)
+	if err != nil {
+		ErrorResponse("Failed to get recurring notifications.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	recurringList := make([]model.RecurringNotification, 0)
+	for rows.Next() {
+		recurring, err := scanRecurringNotification(rows)
+		if err != nil {
+			ErrorResponse("Failed to get recurring notifications.", w, http.StatusNotFound)
+			return
+		}
+		recurringList = append(recurringList, recurring)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(recurringList)
+}
+
+// PauseRecurringNotification: Stop a recurring notification from producing further notifications
+// URL : /recurringnotifications/{id}/pause
+// Parameters: teacher
+// Method: POST
+// Output: No content if successful, else error message.
+func PauseRecurringNotification(w http.ResponseWriter, r *http.Request) {
+	setRecurringNotificationStatus(w, r, recurringStatusPaused)
+}
+
+// ResumeRecurringNotification: Resume a paused recurring notification from its next run after now
+// URL : /recurringnotifications/{id}/resume
+// Parameters: teacher
+// Method: POST
+// Output: No content if successful, else error message.
+func ResumeRecurringNotification(w http.ResponseWriter, r *http.Request) {
+	setRecurringNotificationStatus(w, r, recurringStatusActive)
+}
+
+// PreviewRecurringNotification: Preview the upcoming run times of a recurring notification of a teacher
+// URL : /recurringnotifications/{id}/preview
+// Parameters: teacher, count
+// Method: GET
+// Output: JSON Encoded Object of the schedule and its next run times, else error message.
+func PreviewRecurringNotification(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid recurring notification id.", w, http.StatusBadRequest)
+		return
+	}
+	teacher := r.URL.Query().Get("teacher")
+	if len(teacher) == 0 {
+		ErrorResponse("No teacher specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	count := defaultPreviewCount
+	if countParam := r.URL.Query().Get("count"); len(countParam) > 0 {
+		count, err = strconv.Atoi(countParam)
+		if err != nil || count < 1 || count > maxPreviewCount {
+			ErrorResponse("Invalid preview count.", w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	recurring, err := getRecurringNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && recurring.Teacher != teacher) {
+		ErrorResponse("Recurring notification not found.", w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve recurring notification.", w, http.StatusNotFound)
+		return
+	}
+
+	schedule, location, err := parseRecurringSchedule(recurring.Schedule, recurring.Timezone)
+	if err != nil {
+		ErrorResponse(err.Error(), w, http.StatusNotFound)
+		return
+	}
+
+	// A paused definition previews the runs it would have after being resumed now
+	from := time.Now()
+	if recurring.Status == recurringStatusActive {
+		from = recurring.NextRunAt.Add(-time.Second)
+	}
+
+	preview := model.RecurringNotificationPreview{
+		Id:       recurring.Id,
+		Schedule: recurring.Schedule,
+		Timezone: recurring.Timezone,
+		NextRuns: previewRecurringRuns(schedule, location, from, count),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(preview)
+}
+
+/*///////////////////////////////////////////////////////////////
+                      Recurring Materialisation
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Scheduler] Turns every due run of an active recurring notification into a pending notification, then moves the definition on to its next run.
+// Runs of a teacher over their rate limit are left due until their quota allows them.
+func materialiseRecurringNotifications(db *sql.DB, now time.Time) error {
+	rows, err := db.Query("SELECT "+recurringColumns+" FROM RecurringNotification WHERE status = ? AND next_run_at <= ?", recurringStatusActive, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	var due []model.RecurringNotification
+	for rows.Next() {
+		recurring, err := scanRecurringNotification(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, recurring)
+	}
+	rows.Close()
+
+	for _, recurring := range due {
+		schedule, location, err := parseRecurringSchedule(recurring.Schedule, recurring.Timezone)
+		if err != nil {
+			log.Printf("Skipping recurring notification %d: %v", recurring.Id, err)
+			continue
+		}
+
+		// Runs of an inactive teacher are skipped, as if the definition were paused
+		active, err := teacherActive(db, recurring.Teacher)
+		if err != nil {
+			return err
+		}
+		if !active {
+			_, err := db.Exec("UPDATE RecurringNotification SET next_run_at = ? WHERE id = ? AND status = ? AND next_run_at = ?",
+				nextRecurringRun(schedule, location, now), recurring.Id, recurringStatusActive, recurring.NextRunAt)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Runs count against the teacher's quota like any other notification. A run over it is deferred to a later tick,
+		// and the runs due by then are collapsed into it
+		limits, _, err := getRateLimits(db, recurring.Teacher)
+		if err != nil {
+			return err
+		}
+		if retryAfter, exceeded := notificationLimiter.reserveNotification(recurring.Teacher, limits, now); retryAfter > 0 {
+			log.Printf("Deferred recurring notification %d by %v, rate limit exceeded: %s", recurring.Id, retryAfter, exceeded)
+			continue
+		}
+
+		// Given back when another scheduler materialised the run first or it failed
+		created, err := materialiseRecurringRun(db, recurring, nextRecurringRun(schedule, location, now))
+		if err != nil {
+			log.Printf("Failed to materialise recurring notification %d: %v", recurring.Id, err)
+		}
+		if !created {
+			notificationLimiter.refundNotification(recurring.Teacher, limits, now)
+		}
+	}
+	return nil
+}
+
+// @Desc: [Scheduler] Move the definition on to its next run and create the pending notification of its due run together, so that a failure loses neither.
+// Returns whether the run was created, which it is not when the definition was paused, deleted or moved on in the meantime.
+func materialiseRecurringRun(db *sql.DB, recurring model.RecurringNotification, nextRunAt time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Runs missed while the service was down are collapsed into this one
+	result, err := tx.Exec("UPDATE RecurringNotification SET next_run_at = ?, last_run_at = ? WHERE id = ? AND status = ? AND next_run_at = ?",
+		nextRunAt, recurring.NextRunAt, recurring.Id, recurringStatusActive, recurring.NextRunAt)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	recurringId := recurring.Id
+	pending, err := createNotification(tx, model.ScheduledNotification{
+		Teacher:      recurring.Teacher,
+		Notification: recurring.Notification,
+		Status:       notificationStatusPending,
+		SendAt:       recurring.NextRunAt,
+		RecurringId:  &recurringId,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	publishDeliveryUpdate(pending, nil)
+	return true, nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [RecurringNotification] Parse a standard 5-field cron expression (or descriptor such as @weekly) evaluated in the given IANA timezone.
+func parseRecurringSchedule(expression string, timezone string) (cron.Schedule, *time.Location, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, errors.New("Invalid timezone.")
+	}
+
+	// The timezone is its own field, so it cannot also be embedded into the expression
+	expression = strings.TrimSpace(expression)
+	if len(expression) == 0 || strings.HasPrefix(expression, "TZ=") || strings.HasPrefix(expression, "CRON_TZ=") {
+		return nil, nil, errors.New("Invalid cron schedule.")
+	}
+
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, nil, errors.New("Invalid cron schedule.")
+	}
+	return schedule, location, nil
+}
+
+// @Desc: [RecurringNotification] First run of the schedule strictly after `from`, in UTC.
+func nextRecurringRun(schedule cron.Schedule, location *time.Location, from time.Time) time.Time {
+	return schedule.Next(from.In(location)).UTC()
+}
+
+// @Desc: [RecurringNotification] The next `count` runs of the schedule strictly after `from`, in UTC.
+func previewRecurringRuns(schedule cron.Schedule, location *time.Location, from time.Time, count int) []time.Time {
+	runs := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		from = nextRecurringRun(schedule, location, from)
+		if from.IsZero() {
+			break
+		}
+		runs = append(runs, from)
+	}
+	return runs
+}
+
+// @Desc: [RecurringNotification] Scans a RecurringNotification row selected with recurringColumns.
+func scanRecurringNotification(row rowScanner) (model.RecurringNotification, error) {
+	var recurring model.RecurringNotification
+	var lastRunAt sql.NullTime
+
+	err := row.Scan(&recurring.Id, &recurring.Teacher, &recurring.Notification, &recurring.Schedule, &recurring.Timezone,
+		&recurring.Status, &recurring.NextRunAt, &lastRunAt, &recurring.CreatedAt)
+	if err != nil {
+		return recurring, err
+	}
+	if lastRunAt.Valid {
+		recurring.LastRunAt = &lastRunAt.Time
+	}
+	mentions, _ := parseMentions(recurring.Notification)
+	recurring.Mentions = mentionSpans(recurring.Notification, mentions)
+	return recurring, nil
+}
+
+// @Desc: [RecurringNotification] Retrieve a single recurring notification by its id.
+func getRecurringNotification(db *sql.DB, id int64) (model.RecurringNotification, error) {
+	row := db.QueryRow("SELECT "+recurringColumns+" FROM RecurringNotification WHERE id = ?", id)
+	return scanRecurringNotification(row)
+}
+
+// @Desc: [RecurringNotification] Shared handler body of pause and resume.
+func setRecurringNotificationStatus(w http.ResponseWriter, r *http.Request, status string) {
+	var requestBody model.RecurringNotificationActionBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid recurring notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	recurring, err := getRecurringNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && recurring.Teacher != requestBody.Teacher) {
+		ErrorResponse("Recurring notification not found.", w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve recurring notification.", w, http.StatusNotFound)
+		return
+	}
+	if recurring.Status == status {
+		ErrorResponse("Recurring notification is already "+status+".", w, http.StatusConflict)
+		return
+	}
+
+	nextRunAt := recurring.NextRunAt
+	if status == recurringStatusActive {
+		// Runs that fell within the pause are skipped rather than sent on resume
+		schedule, location, err := parseRecurringSchedule(recurring.Schedule, recurring.Timezone)
+		if err != nil {
+			ErrorResponse(err.Error(), w, http.StatusNotFound)
+			return
+		}
+		nextRunAt = nextRecurringRun(schedule, location, time.Now())
+	}
+
+	_, err = db.Exec("UPDATE RecurringNotification SET status = ?, next_run_at = ? WHERE id = ?", status, nextRunAt, id)
+	if err != nil {
+		ErrorResponse("Failed to update recurring notification.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
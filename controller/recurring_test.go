@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Recurring Notifications
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Creating a recurring notification with a malformed cron expression, which should fail with HTTP code 400.
+func TestCreateRecurringNotificationInvalidSchedule(t *testing.T) {
+	var jsonBody = []byte(`{"teacher": "t1@gmail.com", "notification": "Bring PE attire", "schedule": "every monday", "timezone": "Asia/Singapore"}`)
+	req, err := http.NewRequest("POST", "/api/recurringnotifications", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CreateRecurringNotification)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid cron schedule."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestCreateRecurringNotificationInvalidSchedule")
+}
+
+// @Desc: [FAIL] Creating a recurring notification with an unknown timezone, which should fail with HTTP code 400.
+func TestCreateRecurringNotificationInvalidTimezone(t *testing.T) {
+	var jsonBody = []byte(`{"teacher": "t1@gmail.com", "notification": "Bring PE attire", "schedule": "0 7 * * MON", "timezone": "Mars/Olympus"}`)
+	req, err := http.NewRequest("POST", "/api/recurringnotifications", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CreateRecurringNotification)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid timezone."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestCreateRecurringNotificationInvalidTimezone")
+}
+
+// @Desc: [VALID] Previewing a weekly schedule should yield consecutive Mondays at 7am in the schedule's timezone.
+func TestPreviewRecurringRunsInTimezone(t *testing.T) {
+	schedule, location, err := parseRecurringSchedule("0 7 * * MON", "Asia/Singapore")
+	assert.NoError(t, err)
+
+	// Sunday 2023-02-12 12:00 in Singapore
+	from := time.Date(2023, 2, 12, 12, 0, 0, 0, location)
+	runs := previewRecurringRuns(schedule, location, from, 2)
+
+	expected := []time.Time{
+		time.Date(2023, 2, 12, 23, 0, 0, 0, time.UTC),
+		time.Date(2023, 2, 19, 23, 0, 0, 0, time.UTC),
+	}
+	assert.Equal(t, expected, runs, "Runs should be Mondays at 7am Singapore time.")
+	log.Println("SUCCESS: TestPreviewRecurringRunsInTimezone")
+}
+
+// @Desc: [FAIL] Previewing a recurring notification without specifying the teacher who owns it, which should fail with HTTP code 400.
+func TestPreviewRecurringNotificationNoTeacher(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/api/recurringnotifications/1/preview", nil)
+	request = mux.SetURLVars(request, map[string]string{"id": "1"})
+	response := httptest.NewRecorder()
+	PreviewRecurringNotification(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code, "Status code should be 400")
+	assert.JSONEq(t, `{"message": "No teacher specified."}`, response.Body.String())
+	log.Println("SUCCESS: TestPreviewRecurringNotificationNoTeacher")
+}
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+const (
+	streamEventReply       = "reply"
+	streamEventReplyHidden = "reply_hidden"
+)
+
+/*///////////////////////////////////////////////////////////////
+                          Reply Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// PostReply: Reply to a received notification as a student who is not suspended
+// URL : /notifications/{id}/replies
+// Parameters: student, reply
+// Method: POST
+// Output: JSON Encoded Object of the reply, else error message.
+func PostReply(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.ReplyBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	if !validEmailFormat(requestBody.Student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	reply := strings.TrimSpace(requestBody.Reply)
+	if len(reply) == 0 {
+		ErrorResponse("Empty reply.", w, http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(reply) > config.MaxReplyLength {
+		ErrorResponse(fmt.Sprintf("Reply is longer than %d characters.", config.MaxReplyLength), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	notification, ok := checkReplyAccess(db, id, requestBody.Student, w)
+	if !ok {
+		return
+	}
+	if notification.Status == notificationStatusRecalled {
+		ErrorResponse("Notification has been recalled.", w, http.StatusConflict)
+		return
+	}
+	if notificationExpired(notification, time.Now()) {
+		ErrorResponse("Notification has expired.", w, http.StatusConflict)
+		return
+	}
+
+	var suspended int
+	err = db.QueryRow("SELECT COUNT(*) FROM Suspend WHERE student = ?", requestBody.Student).Scan(&suspended)
+	if err != nil {
+		ErrorResponse("Failed to post reply.", w, http.StatusNotFound)
+		return
+	}
+	if suspended > 0 {
+		ErrorResponse("Suspended students cannot reply.", w, http.StatusForbidden)
+		return
+	}
+
+	saved := model.Reply{NotificationId: id, Student: requestBody.Student, Reply: reply, CreatedAt: time.Now().UTC()}
+	result, err := db.Exec("INSERT INTO NotificationReply(notification_id, student, message, created_at) VALUES(?, ?, ?, ?)",
+		saved.NotificationId, saved.Student, saved.Reply, saved.CreatedAt)
+	if err != nil {
+		ErrorResponse("Failed to post reply.", w, http.StatusNotFound)
+		return
+	}
+	saved.Id, err = result.LastInsertId()
+	if err != nil {
+		ErrorResponse("Failed to post reply.", w, http.StatusNotFound)
+		return
+	}
+
+	publishReply(db, notification, saved, streamEventReply)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// ListReplies: List the thread of replies to a notification, oldest first. The sending teacher sees every reply including hidden ones,
+// while a recipient sees their own replies, hidden or not, and the other recipients' visible replies if the teacher made them visible
+// URL : /notifications/{id}/replies
+// Parameters: id, teacher or student
+// Method: GET
+// Output: JSON Encoded Array of replies, else error message.
+func ListReplies(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+	teacher := r.URL.Query().Get("teacher")
+	student := r.URL.Query().Get("student")
+	if (len(teacher) == 0) == (len(student) == 0) {
+		ErrorResponse("Specify either a teacher or a student.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	var replies []model.Reply
+	if len(teacher) > 0 {
+		if _, ok := checkReplyModerator(db, id, teacher, w); !ok {
+			return
+		}
+		replies, err = getReplies(db, id, "")
+	} else {
+		notification, ok := checkReplyAccess(db, id, student, w)
+		if !ok {
+			return
+		}
+		// Hidden replies are only hidden from the other recipients
+		if notification.RepliesVisible {
+			replies, err = getReplies(db, id, "(hidden_at IS NULL OR student = ?)", student)
+		} else {
+			replies, err = getReplies(db, id, "student = ?", student)
+		}
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve replies.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(replies)
+}
+
+// ModerateReply: Hide a reply from the other recipients, or show it again, as the teacher who sent the notification
+// URL : /notifications/{id}/replies/{reply}/moderate
+// Parameters: teacher, hidden
+// Method: POST
+// Output: No content if successful, else error message.
+func ModerateReply(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.ModerateReplyBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+	replyId, err := strconv.ParseInt(mux.Vars(r)["reply"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid reply id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	notification, ok := checkReplyModerator(db, id, requestBody.Teacher, w)
+	if !ok {
+		return
+	}
+
+	var hiddenAt sql.NullTime
+	if requestBody.Hidden {
+		hiddenAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	// A reply hidden again keeps the time it was first hidden
+	result, err := db.Exec("UPDATE NotificationReply SET hidden_at = IF(?, COALESCE(hidden_at, ?), NULL) WHERE id = ? AND notification_id = ?",
+		requestBody.Hidden, hiddenAt, replyId, id)
+	if err != nil {
+		ErrorResponse("Failed to moderate reply.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var exists int
+		err = db.QueryRow("SELECT COUNT(*) FROM NotificationReply WHERE id = ? AND notification_id = ?", replyId, id).Scan(&exists)
+		if err != nil || exists == 0 {
+			ErrorResponse("Reply not found.", w, http.StatusNotFound)
+			return
+		}
+	}
+
+	// Streams that already show the reply are told to drop it, or to show it again
+	replies, err := getReplies(db, id, "id = ?", replyId)
+	if err == nil && len(replies) > 0 {
+		event := streamEventReply
+		if requestBody.Hidden {
+			event = streamEventReplyHidden
+		}
+		publishReply(db, notification, replies[0], event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Replies] Send a reply event to the teacher's stream, and to the other recipients' streams when they can see the thread.
+func publishReply(db *sql.DB, notification model.ScheduledNotification, reply model.Reply, event string) {
+	notificationHub.Publish(teacherTopic(notification.Teacher), event, reply)
+	if !notification.RepliesVisible {
+		return
+	}
+	recipients, err := queryColumn(db, "SELECT student FROM NotificationRecipient WHERE notification_id = ? AND student <> ?", notification.Id, reply.Student)
+	if err != nil {
+		return
+	}
+	for _, student := range recipients {
+		notificationHub.Publish(studentTopic(student), event, reply)
+	}
+}
+
+// @Desc: [Replies] Writes the matching error response and returns false unless the notification was received by the student.
+func checkReplyAccess(db *sql.DB, id int64, student string, w http.ResponseWriter) (model.ScheduledNotification, bool) {
+	notification, err := getNotification(db, id)
+	if err != nil && err != sql.ErrNoRows {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return notification, false
+	}
+
+	var received int
+	if err == nil {
+		err = db.QueryRow("SELECT COUNT(*) FROM NotificationRecipient WHERE notification_id = ? AND student = ?", id, student).Scan(&received)
+		if err != nil {
+			ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+			return notification, false
+		}
+	}
+	if received == 0 {
+		ErrorResponse("Notification not found in inbox.", w, http.StatusNotFound)
+		return notification, false
+	}
+	return notification, true
+}
+
+// @Desc: [Replies] Writes the matching error response and returns false unless the notification was sent by the teacher.
+func checkReplyModerator(db *sql.DB, id int64, teacher string, w http.ResponseWriter) (model.ScheduledNotification, bool) {
+	notification, err := getNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && notification.Teacher != teacher) {
+		ErrorResponse("Notification not found.", w, http.StatusNotFound)
+		return notification, false
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return notification, false
+	}
+	return notification, true
+}
+
+// @Desc: [Replies] The replies to a notification, oldest first, narrowed down by an optional extra condition.
+func getReplies(db *sql.DB, id int64, condition string, args ...any) ([]model.Reply, error) {
+	query := "SELECT id, notification_id, student, message, created_at, hidden_at FROM NotificationReply WHERE notification_id = ?"
+	if len(condition) > 0 {
+		query += " AND " + condition
+	}
+	rows, err := db.Query(query+" ORDER BY created_at, id", append([]any{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replies := make([]model.Reply, 0)
+	for rows.Next() {
+		var reply model.Reply
+		var hiddenAt sql.NullTime
+		if err := rows.Scan(&reply.Id, &reply.NotificationId, &reply.Student, &reply.Reply, &reply.CreatedAt, &hiddenAt); err != nil {
+			return nil, err
+		}
+		if hiddenAt.Valid {
+			reply.HiddenAt = &hiddenAt.Time
+		}
+		replies = append(replies, reply)
+	}
+	return replies, rows.Err()
+}
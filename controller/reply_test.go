@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Replies
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Replying with an invalid student, an empty or too long reply, which should fail with HTTP code 400.
+func TestPostReplyInvalid(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"student":"s1","reply":"Hello"}`, `{"message":"Invalid student email format."}`},
+		{`{"student":"s1@gmail.com","reply":"   "}`, `{"message":"Empty reply."}`},
+		{`{"student":"s1@gmail.com","reply":"` + strings.Repeat("a", 1001) + `"}`, `{"message":"Reply is longer than 1000 characters."}`},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("POST", "/api/notifications/1/replies", bytes.NewBuffer([]byte(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(PostReply)
+		handler.ServeHTTP(rr, req)
+
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+		assert.Equal(t, c.expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestPostReplyInvalid")
+}
+
+// @Desc: [FAIL] Listing replies as both or neither a teacher and a student, which should fail with HTTP code 400.
+func TestListRepliesInvalidViewer(t *testing.T) {
+	for _, query := range []string{"", "?teacher=t1%40gmail.com&student=s1%40gmail.com"} {
+		req, err := http.NewRequest("GET", "/api/notifications/1/replies"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ListReplies)
+		handler.ServeHTTP(rr, req)
+
+		expected := `{"message":"Specify either a teacher or a student."}`
+		actual := strings.TrimRight(rr.Body.String(), "\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+		assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	}
+	log.Println("SUCCESS: TestListRepliesInvalidViewer")
+}
+
+// @Desc: [FAIL] Moderating a reply with an invalid reply id, which should fail with HTTP code 400.
+func TestModerateReplyInvalidId(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/notifications/1/replies/abc/moderate", bytes.NewBuffer([]byte(`{"teacher":"t1@gmail.com","hidden":true}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "reply": "abc"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ModerateReply)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid reply id."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestModerateReplyInvalidId")
+}
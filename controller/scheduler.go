@@ -0,0 +1,746 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Columns selected whenever a NotificationMessage row is read back through scanNotification
+const notificationColumns = "id, teacher, message, status, send_at, created_at, sent_at, recurring_id, template_id, priority, include_suspended, requires_ack, edited_at, replies_visible, format, language, expires_at, audience"
+
+const (
+	notificationStatusPending   = "pending"
+	notificationStatusSent      = "sent"
+	notificationStatusCancelled = "cancelled"
+	notificationStatusRecalled  = "recalled"
+	notificationStatusExpired   = "expired"
+	notificationStatusFailed    = "failed"
+)
+
+// Condition on a NotificationMessage that no earlier, failed delivery of it recorded any recipient, who would already have it
+const undeliveredNotification = `NOT EXISTS (SELECT 1 FROM NotificationRecipient WHERE notification_id = NotificationMessage.id)
+	AND NOT EXISTS (SELECT 1 FROM NotificationGuardian WHERE notification_id = NotificationMessage.id)`
+
+const (
+	priorityLow    = "low"
+	priorityNormal = "normal"
+	priorityUrgent = "urgent"
+)
+
+/*///////////////////////////////////////////////////////////////
+                  Scheduled Notification Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// ListScheduledNotifications: List all pending notifications of a teacher, along with those that could not be delivered
+// URL : /notifications/scheduled
+// Parameters: teacher
+// Method: GET
+// Output: JSON Encoded Array of pending & failed notifications ordered by send time, else error message.
+func ListScheduledNotifications(w http.ResponseWriter, r *http.Request) {
+	teacher := r.URL.Query().Get("teacher")
+	if len(teacher) == 0 {
+		ErrorResponse("No teacher specified.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+notificationColumns+` FROM NotificationMessage
+	WHERE teacher = ? AND status IN (?, ?) ORDER BY send_at`, teacher, notificationStatusPending, notificationStatusFailed)
+	if err != nil {
+		ErrorResponse("Failed to get scheduled notifications.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	scheduledList := make([]model.ScheduledNotification, 0)
+	for rows.Next() {
+		scheduled, err := scanNotification(rows)
+		if err != nil {
+			ErrorResponse("Failed to get scheduled notifications.", w, http.StatusNotFound)
+			return
+		}
+		scheduledList = append(scheduledList, scheduled)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(scheduledList)
+}
+
+// CancelScheduledNotification: Cancel a pending notification before it is sent
+// URL : /notifications/{id}/cancel
+// Parameters: teacher
+// Method: POST
+// Output: No content if successful, else error message.
+func CancelScheduledNotification(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.CancelNotificationBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if ok := checkPendingNotification(db, id, requestBody.Teacher, w); !ok {
+		return
+	}
+
+	result, err := db.Exec("UPDATE NotificationMessage SET status = ? WHERE id = ? AND status = ? AND "+undeliveredNotification,
+		notificationStatusCancelled, id, notificationStatusPending)
+	if err != nil {
+		ErrorResponse("Failed to cancel notification.", w, http.StatusNotFound)
+		return
+	}
+	// The scheduler may have claimed the notification in between
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Notification is no longer pending.", w, http.StatusConflict)
+		return
+	}
+
+	if cancelled, err := getNotification(db, id); err == nil {
+		publishDeliveryUpdate(cancelled, nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RescheduleNotification: Move a pending notification to a new send time
+// URL : /notifications/{id}/reschedule
+// Parameters: teacher, send_at
+// Method: POST
+// Output: JSON Encoded Object of the rescheduled notification, else error message.
+func RescheduleNotification(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.RescheduleNotificationBody
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid notification id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(requestBody.Teacher) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if !requestBody.SendAt.After(time.Now()) {
+		ErrorResponse("Send time must be in the future.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if ok := checkPendingNotification(db, id, requestBody.Teacher, w); !ok {
+		return
+	}
+	pending, err := getNotification(db, id)
+	if err != nil {
+		ErrorResponse("Failed to reschedule notification.", w, http.StatusNotFound)
+		return
+	}
+	if !validExpiry(pending.ExpiresAt, requestBody.SendAt) {
+		ErrorResponse("Send time must be before the notification expires.", w, http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("UPDATE NotificationMessage SET send_at = ? WHERE id = ? AND status = ? AND "+undeliveredNotification,
+		requestBody.SendAt.UTC(), id, notificationStatusPending)
+	if err != nil {
+		ErrorResponse("Failed to reschedule notification.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Notification is no longer pending.", w, http.StatusConflict)
+		return
+	}
+
+	scheduled, err := getNotification(db, id)
+	if err != nil {
+		ErrorResponse("Failed to reschedule notification.", w, http.StatusNotFound)
+		return
+	}
+	publishDeliveryUpdate(scheduled, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Scheduler Loop
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: Runs forever, materialising recurring notifications, dispatching pending notifications whose send time has passed, pushing notifications held for quiet hours,
+// building due digests, reminding students of unacknowledged notifications and purging expired notifications on every tick.
+func StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		db := config.Connect()
+		if err := materialiseRecurringNotifications(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to materialise recurring notifications:", err)
+		}
+		if err := dispatchDueNotifications(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to dispatch notifications:", err)
+		}
+		if err := sendHeldPushes(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to send notifications held for quiet hours:", err)
+		}
+		if err := buildDueDigests(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to build digests:", err)
+		}
+		if err := sendAcknowledgementReminders(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to send acknowledgement reminders:", err)
+		}
+		if err := purgeExpiredNotifications(db, time.Now()); err != nil {
+			log.Println("Scheduler failed to purge expired notifications:", err)
+		}
+		db.Close()
+	}
+}
+
+// @Desc: [Scheduler] Claims every due pending notification and delivers it to the recipients resolved at this point in time.
+func dispatchDueNotifications(db *sql.DB, now time.Time) error {
+	rows, err := db.Query("SELECT "+notificationColumns+` FROM NotificationMessage
+	WHERE status = ? AND send_at <= ? ORDER BY send_at`, notificationStatusPending, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	var due []model.ScheduledNotification
+	for rows.Next() {
+		scheduled, err := scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, scheduled)
+	}
+	rows.Close()
+
+	for _, scheduled := range due {
+		// Notifications that expired while pending are never sent
+		if notificationExpired(scheduled, now) {
+			result, err := db.Exec("UPDATE NotificationMessage SET status = ? WHERE id = ? AND status = ?", notificationStatusExpired, scheduled.Id, notificationStatusPending)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected > 0 {
+				scheduled.Status = notificationStatusExpired
+				publishDeliveryUpdate(scheduled, nil)
+				log.Printf("Skipped scheduled notification %d, which expired before it was sent", scheduled.Id)
+			}
+			continue
+		}
+
+		// Notifications of a teacher made inactive while they were pending are cancelled rather than sent
+		active, err := teacherActive(db, scheduled.Teacher)
+		if err != nil {
+			return err
+		}
+		if !active {
+			result, err := db.Exec("UPDATE NotificationMessage SET status = ? WHERE id = ? AND status = ?", notificationStatusCancelled, scheduled.Id, notificationStatusPending)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected > 0 {
+				scheduled.Status = notificationStatusCancelled
+				publishDeliveryUpdate(scheduled, nil)
+				log.Printf("Cancelled scheduled notification %d of inactive teacher %s", scheduled.Id, scheduled.Teacher)
+			}
+			continue
+		}
+
+		// Recipients are charged to the teacher's quota up front. A notification to more than the quota is never sent,
+		// while one over what is left of it stays due until the quota refills
+		recipients, err := resolveRecipients(db, scheduled)
+		if err != nil {
+			return err
+		}
+		newRecipients, err := countNewRecipients(db, scheduled, recipients.Students)
+		if err != nil {
+			return err
+		}
+		limits, _, err := getRateLimits(db, scheduled.Teacher)
+		if err != nil {
+			return err
+		}
+		if newRecipients > limits.RecipientsPerDay {
+			failNotification(db, scheduled.Id)
+			log.Printf("Failed scheduled notification %d, its %d recipients are over the limit of %d per day", scheduled.Id, newRecipients, limits.RecipientsPerDay)
+			continue
+		}
+		if retryAfter, exceeded := notificationLimiter.reserveRecipients(scheduled.Teacher, limits, newRecipients, now); retryAfter > 0 {
+			log.Printf("Deferred scheduled notification %d by %v, rate limit exceeded: %s", scheduled.Id, retryAfter, exceeded)
+			continue
+		}
+
+		// Claim the notification first so that a cancellation or another scheduler cannot race the delivery
+		result, err := db.Exec("UPDATE NotificationMessage SET status = ?, sent_at = ? WHERE id = ? AND status = ?",
+			notificationStatusSent, now.UTC(), scheduled.Id, notificationStatusPending)
+		if err != nil {
+			notificationLimiter.settleRecipients(scheduled.Teacher, limits, newRecipients, 0, now)
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			notificationLimiter.settleRecipients(scheduled.Teacher, limits, newRecipients, 0, now)
+			continue
+		}
+		sentAt := now.UTC()
+		scheduled.Status = notificationStatusSent
+		scheduled.SentAt = &sentAt
+
+		delivered, err := deliverNotification(db, scheduled, recipients)
+		notificationLimiter.settleRecipients(scheduled.Teacher, limits, newRecipients, delivered.Recorded, now)
+		if err != nil {
+			log.Printf("Failed to deliver scheduled notification %d: %v", scheduled.Id, err)
+			if err := releaseFailedDelivery(db, scheduled.Id); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Printf("Delivered scheduled notification %d to %d students", scheduled.Id, len(delivered.Students))
+	}
+	return nil
+}
+
+// @Desc: [Scheduler] Put a notification whose delivery failed back to pending for the next tick to retry, or mark it failed once it used up MaxDeliveryAttempts.
+// Recipients recorded on an earlier attempt are not sent it again.
+func releaseFailedDelivery(db *sql.DB, id int64) error {
+	// MySQL assigns left to right, so the status sees the incremented attempts
+	_, err := db.Exec(`UPDATE NotificationMessage SET delivery_attempts = delivery_attempts + 1, status = IF(delivery_attempts >= ?, ?, ?), sent_at = NULL
+	WHERE id = ? AND status = ?`, config.MaxDeliveryAttempts, notificationStatusFailed, notificationStatusPending, id, notificationStatusSent)
+	if err != nil {
+		return err
+	}
+
+	scheduled, err := getNotification(db, id)
+	if err != nil {
+		return err
+	}
+	if scheduled.Status == notificationStatusFailed {
+		publishDeliveryUpdate(scheduled, nil)
+		log.Printf("Gave up delivering scheduled notification %d after %d attempts", id, config.MaxDeliveryAttempts)
+	}
+	return nil
+}
+
+// @Desc: [Notifications] Mark a notification that cannot be delivered to anyone as failed, so that it is neither shown as sent nor retried.
+func failNotification(db *sql.DB, id int64) {
+	result, err := db.Exec("UPDATE NotificationMessage SET status = ?, sent_at = NULL WHERE id = ? AND status IN (?, ?)",
+		notificationStatusFailed, id, notificationStatusPending, notificationStatusSent)
+	if err != nil {
+		log.Printf("Failed to mark notification %d as failed: %v", id, err)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		if failed, err := getNotification(db, id); err == nil {
+			publishDeliveryUpdate(failed, nil)
+		}
+	}
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Either the database or a transaction, for writes that may be part of a larger transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// @Desc: [Notifications] Scans a NotificationMessage row selected with notificationColumns.
+func scanNotification(row rowScanner) (model.ScheduledNotification, error) {
+	var scheduled model.ScheduledNotification
+	var sentAt sql.NullTime
+	var recurringId sql.NullInt64
+	var templateId sql.NullInt64
+	var editedAt sql.NullTime
+	var expiresAt sql.NullTime
+
+	err := row.Scan(&scheduled.Id, &scheduled.Teacher, &scheduled.Notification, &scheduled.Status, &scheduled.SendAt, &scheduled.CreatedAt, &sentAt, &recurringId, &templateId,
+		&scheduled.Priority, &scheduled.IncludeSuspended, &scheduled.RequiresAck, &editedAt, &scheduled.RepliesVisible, &scheduled.Format, &scheduled.Language, &expiresAt, &scheduled.Audience)
+	if err != nil {
+		return scheduled, err
+	}
+	if sentAt.Valid {
+		scheduled.SentAt = &sentAt.Time
+	}
+	if recurringId.Valid {
+		scheduled.RecurringId = &recurringId.Int64
+	}
+	if templateId.Valid {
+		scheduled.TemplateId = &templateId.Int64
+	}
+	if editedAt.Valid {
+		scheduled.EditedAt = &editedAt.Time
+	}
+	if expiresAt.Valid {
+		scheduled.ExpiresAt = &expiresAt.Time
+	}
+	mentions, _ := parseMentions(scheduled.Notification)
+	scheduled.Mentions = mentionSpans(scheduled.Notification, mentions)
+	return scheduled, nil
+}
+
+// @Desc: [Notifications] Retrieve a single persisted notification by its id.
+func getNotification(db *sql.DB, id int64) (model.ScheduledNotification, error) {
+	row := db.QueryRow("SELECT "+notificationColumns+" FROM NotificationMessage WHERE id = ?", id)
+	return scanNotification(row)
+}
+
+// @Desc: [Notifications] Persist a notification, stamping it as sent immediately unless it is pending for a later send time.
+// Callers announce a pending notification once it is saved for good, e.g. its transaction committed.
+func createNotification(db execer, notification model.ScheduledNotification) (model.ScheduledNotification, error) {
+	now := time.Now().UTC()
+	notification.SendAt = notification.SendAt.UTC()
+	notification.CreatedAt = now
+	if len(notification.Priority) == 0 {
+		notification.Priority = priorityNormal
+	}
+	if len(notification.Format) == 0 {
+		notification.Format = notificationFormatPlain
+	}
+	if len(notification.Language) == 0 {
+		notification.Language = config.FallbackLanguage
+	}
+	if len(notification.Audience) == 0 {
+		notification.Audience = audienceStudents
+	}
+	if notification.ExpiresAt != nil {
+		expiresAt := notification.ExpiresAt.UTC()
+		notification.ExpiresAt = &expiresAt
+	}
+	mentions, _ := parseMentions(notification.Notification)
+	notification.Mentions = mentionSpans(notification.Notification, mentions)
+
+	var sentAt sql.NullTime
+	if notification.Status == notificationStatusSent {
+		sentAt = sql.NullTime{Time: now, Valid: true}
+		notification.SentAt = &now
+	}
+
+	result, err := db.Exec(`INSERT INTO NotificationMessage(teacher, message, status, send_at, created_at, sent_at, recurring_id, template_id, priority, include_suspended, requires_ack, replies_visible, format, language, expires_at, audience)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		notification.Teacher, notification.Notification, notification.Status, notification.SendAt, now, sentAt, notification.RecurringId, notification.TemplateId,
+		notification.Priority, notification.IncludeSuspended, notification.RequiresAck, notification.RepliesVisible, notification.Format, notification.Language, notification.ExpiresAt, notification.Audience)
+	if err != nil {
+		return notification, err
+	}
+
+	notification.Id, err = result.LastInsertId()
+	if err != nil {
+		return notification, err
+	}
+	if err := saveVariants(db, notification.Id, notification.Variants); err != nil {
+		return notification, err
+	}
+	return notification, nil
+}
+
+// The students a notification is for, and how the groups it mentions were expanded
+type recipientSet struct {
+	Students []string
+	Groups   []string
+	Members  map[string][]string
+	Excluded map[string]string
+}
+
+// The outcome of delivering a notification
+type delivery struct {
+	Students  []string
+	Breakdown []model.RecipientGroup
+	Guardians []model.GuardianRecipient
+	// Per recipient text of a notification sent from a template or in several languages
+	Messages map[string]string
+	// Students & guardians newly recorded against the notification, who count against the teacher's quota
+	Recorded int
+}
+
+// @Desc: [Notifications] Expand group mentions and resolve the students a notification is for.
+// Groups are expanded at send time so that scheduled notifications see the latest memberships.
+func resolveRecipients(db *sql.DB, notification model.ScheduledNotification) (recipientSet, error) {
+	var recipients recipientSet
+	mentions, _ := parseMentions(notification.Notification)
+	recipients.Groups = mentionedGroups(mentions)
+	members, excluded, err := resolveGroupMentions(db, notification.Teacher, recipients.Groups, notification.IncludeSuspended)
+	if err != nil {
+		return recipients, err
+	}
+	recipients.Members, recipients.Excluded = members, excluded
+
+	students, err := getStudentsForNotification(db, notification.Teacher, notification.IncludeSuspended)
+	if err != nil {
+		return recipients, err
+	}
+	recipients.Students = addGroupRecipients(students, recipients.Groups, members, excluded)
+	return recipients, nil
+}
+
+// @Desc: [RateLimit] How many students & guardians delivering the notification to the students would newly record it against, i.e. charge to the teacher's quota.
+// Those recorded by an earlier, failed delivery are not counted again.
+func countNewRecipients(db *sql.DB, notification model.ScheduledNotification, students []string) (int, error) {
+	count := 0
+	guardians := make(map[int64]bool)
+	for _, student := range students {
+		if notification.Audience != audienceGuardians {
+			var recorded int
+			err := db.QueryRow("SELECT COUNT(*) FROM NotificationRecipient WHERE notification_id = ? AND student = ?", notification.Id, student).Scan(&recorded)
+			if err != nil {
+				return count, err
+			}
+			if recorded == 0 {
+				count++
+			}
+		}
+		if notification.Audience != audienceGuardians && notification.Audience != audienceBoth {
+			continue
+		}
+
+		linked, err := getStudentGuardians(db, student)
+		if err != nil {
+			return count, err
+		}
+		for _, guardian := range linked {
+			if guardians[guardian.Guardian.Id] {
+				continue
+			}
+			var recorded int
+			err := db.QueryRow("SELECT COUNT(*) FROM NotificationGuardian WHERE notification_id = ? AND guardian_id = ? AND student = ?",
+				notification.Id, guardian.Guardian.Id, student).Scan(&recorded)
+			if err != nil {
+				return count, err
+			}
+			if recorded == 0 {
+				guardians[guardian.Guardian.Id] = true
+			}
+		}
+	}
+	return count + len(guardians), nil
+}
+
+// @Desc: [Notifications] Record each of the resolved recipients against a notification and push it out on their channels.
+func deliverNotification(db *sql.DB, notification model.ScheduledNotification, recipients recipientSet) (delivery, error) {
+	result := delivery{Students: recipients.Students}
+	attachments, err := getAttachments(db, notification.Id)
+	if err != nil {
+		return result, err
+	}
+	variants, err := getVariants(db, notification.Id)
+	if err != nil {
+		return result, err
+	}
+
+	names, err := studentNames(db, result.Students)
+	if err != nil {
+		return result, err
+	}
+
+	var guardianMessages []guardianMessage
+	for _, student := range result.Students {
+		preferences, err := getStudentPreferences(db, student)
+		if err != nil {
+			return result, err
+		}
+
+		// Each recipient gets the variant in their language, with template placeholders filled in for them.
+		// Only text that differs from the stored notification is kept against the recipient
+		body := chooseVariant(notification, variants, preferences.Language)
+		if notification.TemplateId != nil {
+			body, err = renderTemplate(body, notification.Teacher, student, names[student], notification.SendAt)
+			if err != nil {
+				return result, err
+			}
+		}
+		var message sql.NullString
+		if notification.TemplateId != nil || body != notification.Notification {
+			message = sql.NullString{String: body, Valid: true}
+			if result.Messages == nil {
+				result.Messages = make(map[string]string)
+			}
+			result.Messages[student] = body
+		}
+
+		// Markdown is sent as plain text, along with its HTML for the channels & clients that can show it
+		text, html := renderNotificationBody(body, notification.Format)
+		studentAttachments := signAttachments(attachments, student)
+
+		// Urgent notifications skip the student's digest & quiet hours, while low priority ones always wait for a digest.
+		// Pushes during quiet hours are held back for sendHeldPushes to send once they are over
+		toStudent := notification.Audience != audienceGuardians
+		digest := priorityDigest(notification.Priority, preferences)
+		digested := toStudent && digest != digestOff
+		held := !digested && notification.Priority != priorityUrgent && inQuietHours(preferences, time.Now())
+
+		// Guardians get the text their student was sent, no sooner than the student is pushed it, and a notification only for guardians never reaches the student
+		if notification.Audience == audienceGuardians || notification.Audience == audienceBoth {
+			guardianMessages = append(guardianMessages, guardianMessage{Student: student, Message: message, Text: text, HTML: html, Attachments: studentAttachments, Held: digested || held})
+		}
+		if !toStudent {
+			continue
+		}
+
+		// The digest entry is queued before the recipient is recorded, which marks them as delivered to,
+		// so that a retried delivery neither loses nor repeats anything for them
+		if digested {
+			if err := queueDigestEntry(db, notification.Id, student, digest); err != nil {
+				return result, err
+			}
+		}
+		recorded, err := db.Exec("INSERT IGNORE INTO NotificationRecipient(notification_id, student, message, push_held) VALUES(?, ?, ?, ?)", notification.Id, student, message, held)
+		if err != nil {
+			return result, err
+		}
+		if affected, _ := recorded.RowsAffected(); affected == 0 {
+			continue
+		}
+		result.Recorded++
+
+		textMentions, _ := parseMentions(text)
+		notificationHub.Publish(studentTopic(student), streamEventNotification, model.InboxNotification{
+			Id:           notification.Id,
+			Teacher:      notification.Teacher,
+			Notification: text,
+			HTML:         html,
+			Mentions:     mentionSpans(text, textMentions),
+			Priority:     notification.Priority,
+			RequiresAck:  notification.RequiresAck,
+			Attachments:  studentAttachments,
+			SentAt:       notification.SentAt,
+		})
+		push := channelMessage{NotificationId: notification.Id, Teacher: notification.Teacher, Student: student, Text: text, HTML: html, Attachments: studentAttachments}
+
+		// The inbox record is kept & streamed regardless. Urgent notifications are pushed on every channel the student has not muted straight away,
+		// others are left to the student's digest or held back during their quiet hours
+		if digested || held {
+			continue
+		}
+		pushNotification(db, push, preferences)
+	}
+
+	result.Guardians, err = deliverToGuardians(db, notification, guardianMessages)
+	if err != nil {
+		return result, err
+	}
+	result.Recorded += len(result.Guardians)
+
+	if err := currentSearchIndex().Index(db, notification); err != nil {
+		log.Printf("Failed to index notification %d for search: %v", notification.Id, err)
+	}
+
+	result.Breakdown = groupBreakdown(recipients.Groups, recipients.Members, result.Students, recipients.Excluded)
+	publishDeliveryUpdate(notification, result.Students)
+	notificationHub.Publish(classroomTopic(notification.Teacher), streamEventNotification, notification)
+	return result, nil
+}
+
+// @Desc: [Notifications] The digest a notification of the priority goes into for the student, digestOff when it is pushed instead:
+// never for urgent notifications, the student's own digest for normal ones, and a LowPriorityDigest one for low priority ones when the student has none.
+func priorityDigest(priority string, preferences model.StudentPreferences) string {
+	switch {
+	case priority == priorityUrgent:
+		return digestOff
+	case priority == priorityLow && preferences.Digest == digestOff:
+		return config.LowPriorityDigest
+	default:
+		return preferences.Digest
+	}
+}
+
+// @Desc: [Notifications] Whether the priority is one a notification can be sent with.
+func isValidPriority(priority string) bool {
+	return priority == priorityLow || priority == priorityNormal || priority == priorityUrgent
+}
+
+// @Desc: [Notifications] Tell the teacher's stream that a notification changed status, along with its recipients once sent.
+func publishDeliveryUpdate(notification model.ScheduledNotification, students []string) {
+	notificationHub.Publish(teacherTopic(notification.Teacher), streamEventDelivery, model.DeliveryUpdate{
+		NotificationId: notification.Id,
+		Status:         notification.Status,
+		SendAt:         notification.SendAt,
+		SentAt:         notification.SentAt,
+		Students:       students,
+	})
+}
+
+// @Desc: [Notifications] For each group mention, the recipients that were reached through it and the members left out, with why.
+func groupBreakdown(groups []string, members map[string][]string, recipients []string, excluded map[string]string) []model.RecipientGroup {
+	isRecipient := make(map[string]bool)
+	for _, student := range recipients {
+		isRecipient[student] = true
+	}
+
+	var breakdown []model.RecipientGroup
+	for _, group := range groups {
+		reached := make([]string, 0)
+		var dropped []model.DroppedRecipient
+		for _, student := range members[group] {
+			if isRecipient[student] {
+				reached = append(reached, student)
+			} else {
+				dropped = append(dropped, model.DroppedRecipient{Student: student, Reason: excluded[student]})
+			}
+		}
+		breakdown = append(breakdown, model.RecipientGroup{Mention: "@" + group, Students: reached, Dropped: dropped})
+	}
+	return breakdown
+}
+
+// @Desc: [Notifications] Writes the matching error response and returns false unless the notification exists, belongs to the teacher and is still pending,
+// with no recipients recorded by a failed delivery that is waiting to be retried.
+func checkPendingNotification(db *sql.DB, id int64, teacher string, w http.ResponseWriter) bool {
+	scheduled, err := getNotification(db, id)
+	if err == sql.ErrNoRows || (err == nil && scheduled.Teacher != teacher) {
+		ErrorResponse("Notification not found.", w, http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return false
+	}
+	if scheduled.Status != notificationStatusPending {
+		ErrorResponse("Notification is no longer pending.", w, http.StatusConflict)
+		return false
+	}
+
+	var undelivered bool
+	err = db.QueryRow("SELECT "+undeliveredNotification+" FROM NotificationMessage WHERE id = ?", id).Scan(&undelivered)
+	if err != nil {
+		ErrorResponse("Failed to retrieve notification.", w, http.StatusNotFound)
+		return false
+	}
+	if !undelivered {
+		ErrorResponse("Notification has already reached some recipients and will be retried.", w, http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Scheduled Notifications
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Listing scheduled notifications without specifying a teacher in the query parameters, which should fail with HTTP code 400.
+func TestListScheduledNotificationsMissingTeacher(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/notifications/scheduled", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ListScheduledNotifications)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"No teacher specified."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestListScheduledNotificationsMissingTeacher")
+}
+
+// @Desc: [FAIL] Cancelling a scheduled notification with a non-numeric id, which should fail with HTTP code 400.
+func TestCancelScheduledNotificationInvalidId(t *testing.T) {
+	var jsonBody = []byte(`{"teacher": "t1@gmail.com"}`)
+	req, err := http.NewRequest("POST", "/api/notifications/abc/cancel", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CancelScheduledNotification)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid notification id."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestCancelScheduledNotificationInvalidId")
+}
+
+// @Desc: [FAIL] Rescheduling a notification to a time in the past, which should fail with HTTP code 400.
+func TestRescheduleNotificationInThePast(t *testing.T) {
+	var jsonBody = []byte(`{"teacher": "t1@gmail.com", "send_at": "2020-01-01T08:00:00Z"}`)
+	req, err := http.NewRequest("POST", "/api/notifications/1/reschedule", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(RescheduleNotification)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Send time must be in the future."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestRescheduleNotificationInThePast")
+}
+
+// @Desc: [VALID] Low priority notifications should always wait for a digest, where normal ones are pushed to students without digests and urgent ones skip digests.
+func TestPriorityDigest(t *testing.T) {
+	off := model.StudentPreferences{Digest: digestOff}
+	hourly := model.StudentPreferences{Digest: digestHourly}
+
+	assert.Equal(t, config.LowPriorityDigest, priorityDigest(priorityLow, off), "Low priority notifications should be batched for students without digests.")
+	assert.Equal(t, digestOff, priorityDigest(priorityNormal, off), "Normal notifications should be pushed to students without digests.")
+	assert.Equal(t, digestHourly, priorityDigest(priorityLow, hourly))
+	assert.Equal(t, digestHourly, priorityDigest(priorityNormal, hourly))
+	assert.Equal(t, digestOff, priorityDigest(priorityUrgent, hourly), "Urgent notifications should skip the digest.")
+	log.Println("SUCCESS: TestPriorityDigest")
+}
@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Date only bounds of a search, covering whole days in DigestTimezone
+const searchDateLayout = "2006-01-02"
+
+// SearchIndex: Finds sent notifications by keyword, teacher, mentioned student and send time. Notifications are indexed as they are sent
+// & edited, and removed when recalled or purged, for indexes that keep their own copy.
+type SearchIndex interface {
+	Index(db *sql.DB, notification model.ScheduledNotification) error
+	Remove(db *sql.DB, id int64) error
+	Search(db *sql.DB, query model.NotificationSearchQuery) (model.NotificationSearch, error)
+}
+
+var (
+	searchIndexMu sync.RWMutex
+	searchIndex   SearchIndex = &FullTextSearchIndex{}
+)
+
+// @Desc: Search notifications through another SearchIndex than the FULLTEXT index of the database. Call during start up.
+func UseSearchIndex(index SearchIndex) {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	searchIndex = index
+}
+
+// @Desc: [Search] The SearchIndex in use.
+func currentSearchIndex() SearchIndex {
+	searchIndexMu.RLock()
+	defer searchIndexMu.RUnlock()
+	return searchIndex
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Search Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// SearchNotifications: Search sent notifications, most relevant first when searching by keyword, else newest first
+// URL : /notifications/search
+// Parameters: q, teacher, student (mentioned in the notification), from, to (dates or RFC 3339 times), limit, offset
+// Method: GET
+// Output: JSON Encoded Object of one page of matching notifications, else error message.
+func SearchNotifications(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		requestErrorResponse(err, w)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	search, err := currentSearchIndex().Search(db, query)
+	if err != nil {
+		ErrorResponse("Failed to search notifications.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(search)
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Full-Text Search Index
+//////////////////////////////////////////////////////////////*/
+
+// FullTextSearchIndex: Searches NotificationMessage directly through its FULLTEXT index, so there is nothing to keep up to date
+type FullTextSearchIndex struct{}
+
+func (i *FullTextSearchIndex) Index(db *sql.DB, notification model.ScheduledNotification) error {
+	return nil
+}
+
+func (i *FullTextSearchIndex) Remove(db *sql.DB, id int64) error {
+	return nil
+}
+
+// @Desc: [FullTextSearchIndex] Every keyword must match. Mentions are narrowed down in the database and confirmed against the parsed text,
+// so that one address is not taken for another that starts with it.
+func (i *FullTextSearchIndex) Search(db *sql.DB, query model.NotificationSearchQuery) (model.NotificationSearch, error) {
+	score := "0"
+	filters := []string{"status = ?"}
+	args := []any{notificationStatusSent}
+
+	terms := searchTerms(query.Query)
+	if len(terms) > 0 {
+		keywords := "+" + strings.Join(terms, " +")
+		score = "MATCH(message) AGAINST(? IN BOOLEAN MODE)"
+		filters = append(filters, "MATCH(message) AGAINST(? IN BOOLEAN MODE)")
+		args = append([]any{keywords}, append(args, keywords)...)
+	}
+	if len(query.Teacher) > 0 {
+		filters = append(filters, "teacher = ?")
+		args = append(args, query.Teacher)
+	}
+	if len(query.Student) > 0 {
+		filters = append(filters, `message LIKE ? ESCAPE '\\'`)
+		args = append(args, "%@"+escapeLike(query.Student)+"%")
+	}
+	if query.From != nil {
+		filters = append(filters, "sent_at >= ?")
+		args = append(args, query.From.UTC())
+	}
+	if query.To != nil {
+		filters = append(filters, "sent_at < ?")
+		args = append(args, query.To.UTC())
+	}
+
+	// Only the page is read when the database can tell every match apart. Mentions of a student must be confirmed one by one, so all of their matches are read
+	statement := "SELECT id, teacher, message, sent_at, " + score + " AS score FROM NotificationMessage WHERE " + strings.Join(filters, " AND ") +
+		" ORDER BY score DESC, sent_at DESC, id DESC"
+	search := model.NotificationSearch{NotificationSearchQuery: query}
+	if len(query.Student) == 0 {
+		countArgs := args
+		if len(terms) > 0 {
+			countArgs = args[1:]
+		}
+		err := db.QueryRow("SELECT COUNT(*) FROM NotificationMessage WHERE "+strings.Join(filters, " AND "), countArgs...).Scan(&search.Total)
+		if err != nil {
+			return search, err
+		}
+		statement += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := db.Query(statement, args...)
+	if err != nil {
+		return search, err
+	}
+	defer rows.Close()
+
+	var results []model.NotificationSearchResult
+	for rows.Next() {
+		var result model.NotificationSearchResult
+		if err := rows.Scan(&result.Id, &result.Teacher, &result.Notification, &result.SentAt, &result.Score); err != nil {
+			return search, err
+		}
+		if len(query.Student) > 0 && !mentionsStudent(result.Notification, query.Student) {
+			continue
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return search, err
+	}
+	if len(query.Student) > 0 {
+		return searchPage(query, results), nil
+	}
+
+	search.Results = withMentions(results)
+	return search, nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Memory Search Index
+//////////////////////////////////////////////////////////////*/
+
+// MemorySearchIndex: An inverted index kept in memory, for databases without full-text search. It is loaded with the sent notifications when created
+type MemorySearchIndex struct {
+	mu            sync.RWMutex
+	notifications map[int64]model.ScheduledNotification
+	// Occurrences of each term in each notification it is found in
+	postings map[string]map[int64]int
+}
+
+// @Desc: Memory index of every notification already sent, read from the database. Without a database it starts empty.
+func NewMemorySearchIndex(db *sql.DB) (*MemorySearchIndex, error) {
+	index := &MemorySearchIndex{notifications: make(map[int64]model.ScheduledNotification), postings: make(map[string]map[int64]int)}
+	if db == nil {
+		return index, nil
+	}
+
+	rows, err := db.Query("SELECT "+notificationColumns+" FROM NotificationMessage WHERE status = ?", notificationStatusSent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		index.Index(db, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// @Desc: [MemorySearchIndex] Indexing a notification again, e.g. once edited, replaces what was indexed before.
+func (i *MemorySearchIndex) Index(db *sql.DB, notification model.ScheduledNotification) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(notification.Id)
+	if notification.Status != notificationStatusSent || notification.SentAt == nil {
+		return nil
+	}
+
+	i.notifications[notification.Id] = notification
+	for _, term := range tokenizeSearchText(notification.Notification) {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[int64]int)
+		}
+		i.postings[term][notification.Id]++
+	}
+	return nil
+}
+
+func (i *MemorySearchIndex) Remove(db *sql.DB, id int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(id)
+	return nil
+}
+
+// @Desc: [MemorySearchIndex] Must be called with the lock held.
+func (i *MemorySearchIndex) remove(id int64) {
+	notification, ok := i.notifications[id]
+	if !ok {
+		return
+	}
+	for _, term := range tokenizeSearchText(notification.Notification) {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.notifications, id)
+}
+
+// @Desc: [MemorySearchIndex] Every keyword must match, notifications scoring by how often they use them.
+func (i *MemorySearchIndex) Search(db *sql.DB, query model.NotificationSearchQuery) (model.NotificationSearch, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	scores := make(map[int64]float64)
+	terms := searchTerms(query.Query)
+	if len(terms) == 0 {
+		for id := range i.notifications {
+			scores[id] = 0
+		}
+	}
+	for n, term := range terms {
+		matched := make(map[int64]float64)
+		for id, count := range i.postings[term] {
+			if _, ok := scores[id]; ok || n == 0 {
+				matched[id] = scores[id] + float64(count)
+			}
+		}
+		scores = matched
+	}
+
+	var results []model.NotificationSearchResult
+	for id, score := range scores {
+		notification := i.notifications[id]
+		if len(query.Teacher) > 0 && notification.Teacher != query.Teacher {
+			continue
+		}
+		if len(query.Student) > 0 && !mentionsStudent(notification.Notification, query.Student) {
+			continue
+		}
+		if (query.From != nil && notification.SentAt.Before(*query.From)) || (query.To != nil && !notification.SentAt.Before(*query.To)) {
+			continue
+		}
+		results = append(results, model.NotificationSearchResult{Id: id, Teacher: notification.Teacher, Notification: notification.Notification, SentAt: *notification.SentAt, Score: score})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		if !results[a].SentAt.Equal(results[b].SentAt) {
+			return results[a].SentAt.After(results[b].SentAt)
+		}
+		return results[a].Id > results[b].Id
+	})
+	return searchPage(query, results), nil
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Search] Read the search filters off the query string, rejecting a search without any keyword, teacher or student to narrow it down.
+func parseSearchQuery(r *http.Request) (model.NotificationSearchQuery, error) {
+	values := r.URL.Query()
+	query := model.NotificationSearchQuery{
+		Query:   strings.TrimSpace(values.Get("q")),
+		Teacher: values.Get("teacher"),
+		Student: values.Get("student"),
+	}
+	if len(query.Query) == 0 && len(query.Teacher) == 0 && len(query.Student) == 0 {
+		return query, &requestError{"Specify a keyword, teacher or student to search for.", http.StatusBadRequest}
+	}
+	if len(query.Query) > 0 && len(searchTerms(query.Query)) == 0 {
+		return query, &requestError{"Invalid search keywords.", http.StatusBadRequest}
+	}
+	if len(query.Teacher) > 0 && !validEmailFormat(query.Teacher) {
+		return query, &requestError{"Invalid teacher email format.", http.StatusBadRequest}
+	}
+	if len(query.Student) > 0 && !validEmailFormat(query.Student) {
+		return query, &requestError{"Invalid student email format.", http.StatusBadRequest}
+	}
+
+	var err error
+	if query.From, err = parseSearchTime(values.Get("from"), false); err != nil {
+		return query, &requestError{"Invalid from date.", http.StatusBadRequest}
+	}
+	if query.To, err = parseSearchTime(values.Get("to"), true); err != nil {
+		return query, &requestError{"Invalid to date.", http.StatusBadRequest}
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return query, &requestError{"Invalid date range.", http.StatusBadRequest}
+	}
+
+	if query.Limit, err = parseInboxParam(values.Get("limit"), defaultInboxLimit); err != nil || query.Limit < 1 || query.Limit > maxInboxLimit {
+		return query, &requestError{"Invalid limit.", http.StatusBadRequest}
+	}
+	if query.Offset, err = parseInboxParam(values.Get("offset"), 0); err != nil || query.Offset < 0 {
+		return query, &requestError{"Invalid offset.", http.StatusBadRequest}
+	}
+	return query, nil
+}
+
+// @Desc: [Search] Parse an optional bound of the search, either an RFC 3339 time or a date. A date is the start of that day, or the end of it for the upper bound.
+func parseSearchTime(value string, end bool) (*time.Time, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	location, err := time.LoadLocation(config.DigestTimezone)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.ParseInLocation(searchDateLayout, value, location)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// @Desc: [Search] The distinct lowercase words of a search, each made up of letters & digits only so that none can be read as a full-text operator.
+func searchTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range tokenizeSearchText(text) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// @Desc: [Search] Split text into lowercase words of letters & digits, in order and with repeats.
+func tokenizeSearchText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// @Desc: [Search] Whether the text mentions the student.
+func mentionsStudent(text string, student string) bool {
+	mentions, _ := parseMentions(text)
+	for _, m := range mentions {
+		if strings.EqualFold(m.Email, student) {
+			return true
+		}
+	}
+	return false
+}
+
+// @Desc: [Search] Escape the wildcards of a LIKE pattern, which are common in email addresses.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// @Desc: [Search] One page of the ranked results, with their mentions.
+func searchPage(query model.NotificationSearchQuery, results []model.NotificationSearchResult) model.NotificationSearch {
+	search := model.NotificationSearch{NotificationSearchQuery: query, Total: len(results), Results: make([]model.NotificationSearchResult, 0)}
+	if query.Offset >= len(results) {
+		return search
+	}
+	end := query.Offset + query.Limit
+	if end > len(results) {
+		end = len(results)
+	}
+	search.Results = withMentions(results[query.Offset:end])
+	return search
+}
+
+// @Desc: [Search] The results along with their mentions.
+func withMentions(results []model.NotificationSearchResult) []model.NotificationSearchResult {
+	page := make([]model.NotificationSearchResult, 0, len(results))
+	for _, result := range results {
+		mentions, _ := parseMentions(result.Notification)
+		result.Mentions = mentionSpans(result.Notification, mentions)
+		page = append(page, result)
+	}
+	return page
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Search
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] The memory index should find notifications with every keyword, narrowed down by teacher, mentioned student & send time, most relevant first.
+func TestMemorySearchIndex(t *testing.T) {
+	index, err := NewMemorySearchIndex(nil)
+	assert.NoError(t, err)
+	sentAt := time.Date(2026, 10, 14, 1, 0, 0, 0, time.UTC)
+	for _, notification := range []model.ScheduledNotification{
+		{Id: 1, Teacher: "t1@gmail.com", Notification: "Excursion to the zoo on Friday @s1@gmail.com"},
+		{Id: 2, Teacher: "t1@gmail.com", Notification: "Excursion reminder: zoo excursion tomorrow"},
+		{Id: 3, Teacher: "t2@gmail.com", Notification: "Zoo excursion forms @s1@gmail.com.sg"},
+		{Id: 4, Teacher: "t1@gmail.com", Notification: "Sports day"},
+	} {
+		notification.Status = notificationStatusSent
+		sent := sentAt.Add(time.Duration(notification.Id) * time.Hour)
+		notification.SentAt = &sent
+		assert.NoError(t, index.Index(nil, notification))
+	}
+
+	search := func(query model.NotificationSearchQuery) []int64 {
+		query.Limit = 20
+		result, err := index.Search(nil, query)
+		assert.NoError(t, err)
+		ids := make([]int64, 0)
+		for _, r := range result.Results {
+			ids = append(ids, r.Id)
+		}
+		return ids
+	}
+	assert.Equal(t, []int64{2, 3, 1}, search(model.NotificationSearchQuery{Query: "ZOO excursion"}), "Notifications using the keywords more should rank first.")
+	assert.Equal(t, []int64{2, 1}, search(model.NotificationSearchQuery{Query: "zoo", Teacher: "t1@gmail.com"}))
+	assert.Equal(t, []int64{1}, search(model.NotificationSearchQuery{Student: "s1@gmail.com"}), "Only exact mentions of the student should match.")
+
+	from, to := sentAt.Add(2*time.Hour), sentAt.Add(4*time.Hour)
+	assert.Equal(t, []int64{2, 3}, search(model.NotificationSearchQuery{Query: "excursion", From: &from, To: &to}), "The upper bound should be exclusive.")
+
+	assert.NoError(t, index.Index(nil, model.ScheduledNotification{Id: 2, Teacher: "t1@gmail.com", Notification: "Sports day moved", Status: notificationStatusSent, SentAt: &sentAt}))
+	assert.NoError(t, index.Remove(nil, 3))
+	assert.Equal(t, []int64{1}, search(model.NotificationSearchQuery{Query: "excursion"}), "Edited & removed notifications should no longer match.")
+	log.Println("SUCCESS: TestMemorySearchIndex")
+}
+
+// @Desc: [FAIL] Searches without a filter, with keywords that are only punctuation or with a backwards date range should be rejected.
+func TestSearchNotificationsInvalid(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"", "Specify a keyword, teacher or student to search for."},
+		{"q=%21%21%21", "Invalid search keywords."},
+		{"teacher=t1", "Invalid teacher email format."},
+		{"q=zoo&from=2026-10-19&to=2026-10-01", "Invalid date range."},
+		{"q=zoo&to=yesterday", "Invalid to date."},
+		{"q=zoo&limit=500", "Invalid limit."},
+	}
+	for _, c := range cases {
+		request, _ := http.NewRequest("GET", "/api/notifications/search?"+c.query, nil)
+		response := httptest.NewRecorder()
+		SearchNotifications(response, request)
+		assert.Equal(t, http.StatusBadRequest, response.Code, c.query)
+		assert.JSONEq(t, `{"message": "`+c.expected+`"}`, response.Body.String(), c.query)
+	}
+	log.Println("SUCCESS: TestSearchNotificationsInvalid")
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Characters of the GSM 03.38 alphabet, each one character of an SMS, and those of its extension table, which take two
+const (
+	gsmBasicCharacters     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtensionCharacters = "^{}\\[~]|€\f"
+)
+
+// Characters per SMS, on its own or as part of a longer text, when written in the GSM alphabet or otherwise in UCS-2
+const (
+	gsmSingleLength  = 160
+	gsmPartLength    = 153
+	ucs2SingleLength = 70
+	ucs2PartLength   = 67
+)
+
+/*///////////////////////////////////////////////////////////////
+                            SMS Channel
+//////////////////////////////////////////////////////////////*/
+
+// SMSChannel: Sends notifications to the student's phone number through an HTTP SMS gateway, one request per SMS of a long text
+type SMSChannel struct {
+	GatewayURL string
+	Token      string
+	From       string
+	Client     *http.Client
+}
+
+// @Desc: SMS channel sending through the gateway at `gatewayURL`, with the token & sender configured inside `config.go`.
+func NewSMSChannel(gatewayURL string) *SMSChannel {
+	return &SMSChannel{GatewayURL: gatewayURL, Token: config.SMSGatewayToken, From: config.SMSSender, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *SMSChannel) Name() string {
+	return "sms"
+}
+
+// @Desc: [SMSChannel] Students & guardians without a phone number are skipped.
+func (c *SMSChannel) Send(db *sql.DB, message channelMessage) error {
+	if message.Guardian != nil {
+		if len(message.Guardian.Phone) == 0 {
+			return nil
+		}
+		return c.deliver(message.Guardian.Phone, message)
+	}
+	contact, err := getStudentContact(db, message.Student)
+	if err != nil {
+		return err
+	}
+	if len(contact.Phone) == 0 {
+		return nil
+	}
+	return c.deliver(contact.Phone, message)
+}
+
+// @Desc: [SMSChannel] Split the text of the message into SMS and post each of them to the gateway.
+func (c *SMSChannel) deliver(phone string, message channelMessage) error {
+	reference := "digest-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if message.NotificationId > 0 {
+		reference = "notification-" + strconv.FormatInt(message.NotificationId, 10)
+	}
+
+	segments := segmentSMS(smsText(message), config.SMSMaxSegments)
+	for i, segment := range segments {
+		body, err := json.Marshal(model.SMSMessage{To: phone, From: c.From, Text: segment, Reference: reference, Part: i + 1, Parts: len(segments)})
+		if err != nil {
+			return err
+		}
+		request, err := http.NewRequest(http.MethodPost, c.GatewayURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+
+		response, err := c.Client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("sms gateway responded %s to part %d of %d", response.Status, i+1, len(segments))
+		}
+	}
+	return nil
+}
+
+// @Desc: [SMSChannel] Text of a notification as an SMS, naming the teacher it is from and for guardians the students it is about.
+// Attachments cannot be sent, so the student is pointed to their inbox.
+func smsText(message channelMessage) string {
+	text := message.Text
+	if message.Guardian != nil {
+		text = message.Teacher + " about " + strings.Join(message.Wards, ", ") + ": " + text
+	} else if len(message.Teacher) > 0 {
+		text = message.Teacher + ": " + text
+	}
+	if count := len(message.Attachments); count == 1 {
+		text += "\n(1 attachment in your inbox)"
+	} else if count > 1 {
+		text += fmt.Sprintf("\n(%d attachments in your inbox)", count)
+	}
+	return text
+}
+
+/*///////////////////////////////////////////////////////////////
+                          SMS Segmentation
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [SMS] Split a text into the SMS it is sent as, at most `maxSegments` of them. Texts are split between words where possible,
+// and a text too long for `maxSegments` SMS is cut short with "...".
+func segmentSMS(text string, maxSegments int) []string {
+	single, part := ucs2SingleLength, ucs2PartLength
+	if isGSMText(text) {
+		single, part = gsmSingleLength, gsmPartLength
+	}
+	runes := []rune(text)
+	if smsLength(runes) <= single {
+		return []string{text}
+	}
+
+	var segments []string
+	for len(runes) > 0 {
+		if len(segments) == maxSegments-1 && smsLength(runes) > part {
+			// The last segment that fits, ending in an ellipsis
+			end := smsCut(runes, part-3)
+			segments = append(segments, strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace)+"...")
+			break
+		}
+
+		end := smsCut(runes, part)
+		if end < len(runes) {
+			// Prefer to break after the last space, as long as it does not leave the segment less than half full
+			for i := end - 1; i > end/2; i-- {
+				if unicode.IsSpace(runes[i]) {
+					end = i + 1
+					break
+				}
+			}
+		}
+		segments = append(segments, string(runes[:end]))
+		runes = runes[end:]
+	}
+	return segments
+}
+
+// @Desc: [SMS] The number of runes from the start of the text that fit within `length` characters of an SMS.
+func smsCut(runes []rune, length int) int {
+	used := 0
+	for i, r := range runes {
+		used += smsCharLength(r)
+		if used > length {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// @Desc: [SMS] The number of SMS characters the text takes up.
+func smsLength(runes []rune) int {
+	length := 0
+	for _, r := range runes {
+		length += smsCharLength(r)
+	}
+	return length
+}
+
+// @Desc: [SMS] Characters of the GSM extension table are escaped into two, as are characters outside the Basic Multilingual Plane in UCS-2.
+func smsCharLength(r rune) int {
+	if strings.ContainsRune(gsmExtensionCharacters, r) || r > 0xFFFF {
+		return 2
+	}
+	return 1
+}
+
+// @Desc: [SMS] Whether the text can be written in the GSM alphabet, rather than taking the shorter UCS-2 SMS.
+func isGSMText(text string) bool {
+	for _, r := range text {
+		if !strings.ContainsRune(gsmBasicCharacters, r) && !strings.ContainsRune(gsmExtensionCharacters, r) {
+			return false
+		}
+	}
+	return true
+}
+
+/*///////////////////////////////////////////////////////////////
+                          Fake SMS Gateway
+//////////////////////////////////////////////////////////////*/
+
+// FakeSMSGateway: A stand-in for the SMS gateway when running locally and in tests, which keeps & logs the SMS it receives instead of sending them.
+// Requests must carry Token as a bearer token, unless Token is empty.
+type FakeSMSGateway struct {
+	Token string
+
+	mu       sync.Mutex
+	messages []model.SMSMessage
+}
+
+func (g *FakeSMSGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		ErrorResponse("Method not allowed.", w, http.StatusMethodNotAllowed)
+		return
+	}
+	if len(g.Token) > 0 && r.Header.Get("Authorization") != "Bearer "+g.Token {
+		ErrorResponse("Invalid gateway token.", w, http.StatusUnauthorized)
+		return
+	}
+
+	var message model.SMSMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil || len(message.Text) == 0 {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+	if !validPhoneFormat(message.To) {
+		ErrorResponse("Invalid phone number.", w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	g.mu.Lock()
+	g.messages = append(g.messages, message)
+	g.mu.Unlock()
+	log.Printf("Fake SMS gateway: %s (%d/%d) to %s: %s", message.Reference, message.Part, message.Parts, message.To, message.Text)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Desc: [FakeSMSGateway] Every SMS received so far, in order.
+func (g *FakeSMSGateway) Messages() []model.SMSMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]model.SMSMessage(nil), g.messages...)
+}
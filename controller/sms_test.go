@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	SMS Channel
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Texts should be split between words into 153 character parts, or 67 for Unicode texts, and cut short beyond the maximum parts.
+func TestSegmentSMS(t *testing.T) {
+	assert.Equal(t, []string{"Zoo trip on Friday"}, segmentSMS("Zoo trip on Friday", 4), "A short text should be one SMS.")
+	assert.Len(t, segmentSMS(strings.Repeat("a", 160), 4), 1, "160 GSM characters should fit in one SMS.")
+	assert.Len(t, segmentSMS(strings.Repeat("{", 80), 4), 1, "Extension characters should count twice.")
+	assert.Len(t, segmentSMS(strings.Repeat("{", 81), 4), 2, "Extension characters should count twice.")
+
+	words := strings.Repeat("word ", 60)
+	segments := segmentSMS(words, 4)
+	assert.Len(t, segments, 2)
+	assert.True(t, strings.HasSuffix(segments[0], " "), "Parts should be split between words.")
+	assert.LessOrEqual(t, len(segments[0]), 153)
+	assert.Equal(t, words, strings.Join(segments, ""), "Parts should add up to the text.")
+
+	chinese := segmentSMS(strings.Repeat("动物园", 30), 4)
+	assert.Len(t, chinese, 2, "Unicode texts should be split into 67 character parts.")
+	assert.Equal(t, 67, utf8.RuneCountInString(chinese[0]))
+
+	truncated := segmentSMS(strings.Repeat("a", 1000), 2)
+	assert.Len(t, truncated, 2, "Texts should be cut short at the maximum parts.")
+	assert.True(t, strings.HasSuffix(truncated[1], "..."), "Cut short texts should end in an ellipsis.")
+	assert.Equal(t, 153, len(truncated[1]))
+	log.Println("SUCCESS: TestSegmentSMS")
+}
+
+// @Desc: [VALID] A long notification should reach the fake gateway as parts sharing a reference, while a wrong token should be refused.
+func TestSMSChannelDeliversToGateway(t *testing.T) {
+	gateway := &FakeSMSGateway{Token: "test-token"}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	channel := &SMSChannel{GatewayURL: server.URL, Token: "test-token", From: "SCHOOL", Client: server.Client()}
+	message := channelMessage{NotificationId: 12, Teacher: "t1@gmail.com", Student: "s1@gmail.com", Text: strings.Repeat("Bring water. ", 20),
+		Attachments: []model.Attachment{{Filename: "map.pdf"}}}
+	assert.NoError(t, channel.deliver("+6591234567", message))
+
+	received := gateway.Messages()
+	assert.Len(t, received, 2, "The text should be sent as two SMS.")
+	for i, sms := range received {
+		assert.Equal(t, model.SMSMessage{To: "+6591234567", From: "SCHOOL", Text: sms.Text, Reference: "notification-12", Part: i + 1, Parts: 2}, sms)
+	}
+	assert.True(t, strings.HasPrefix(received[0].Text, "t1@gmail.com: Bring water."), "The SMS should name the teacher.")
+	assert.True(t, strings.HasSuffix(received[1].Text, "(1 attachment in your inbox)"), "Attachments should be pointed to.")
+
+	channel.Token = "wrong-token"
+	assert.Error(t, channel.deliver("+6591234567", message), "A refused SMS should fail.")
+	log.Println("SUCCESS: TestSMSChannelDeliversToGateway")
+}
+
+// @Desc: [FAIL] Saving a phone number that is not in E.164 format, which should fail with HTTP code 400.
+func TestUpdateStudentContactInvalidPhone(t *testing.T) {
+	req, err := http.NewRequest("PUT", "/api/students/s1@gmail.com/contact", bytes.NewBuffer([]byte(`{"phone":"9123 4567"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"student": "s1@gmail.com"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(UpdateStudentContact)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid phone number, expected E.164 e.g. +6591234567."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestUpdateStudentContactInvalidPhone")
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	// Delay in milliseconds browsers wait before reconnecting a dropped stream
+	streamRetryMillis = 3000
+)
+
+const (
+	streamEventNotification = "notification"
+	streamEventDelivery     = "delivery"
+)
+
+/*///////////////////////////////////////////////////////////////
+                       Server-Sent Event Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// StudentStream: Stream the notifications received by a student as Server-Sent Events
+// URL : /students/{student}/stream
+// Parameters: student, Last-Event-ID header
+// Method: GET
+// Output: text/event-stream of "notification" events, else error message.
+func StudentStream(w http.ResponseWriter, r *http.Request) {
+	student := mux.Vars(r)["student"]
+	if !validEmailFormat(student) {
+		ErrorResponse("Invalid student email format.", w, http.StatusBadRequest)
+		return
+	}
+	serveEventStream(w, r, studentTopic(student))
+}
+
+// TeacherStream: Stream delivery updates of a teacher's notifications as Server-Sent Events
+// URL : /teachers/{teacher}/stream
+// Parameters: teacher, Last-Event-ID header
+// Method: GET
+// Output: text/event-stream of "delivery" events, else error message.
+func TeacherStream(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+	if !validEmailFormat(teacher) {
+		ErrorResponse("Invalid teacher email format.", w, http.StatusBadRequest)
+		return
+	}
+	serveEventStream(w, r, teacherTopic(teacher))
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [Stream] Replay the events missed since Last-Event-ID, then forward new events of the topic until the client goes away.
+// A client dropped by the hub for falling behind has its stream ended, and catches up by reconnecting.
+func serveEventStream(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ErrorResponse("Streaming is not supported.", w, http.StatusInternalServerError)
+		return
+	}
+
+	lastEventId, err := parseLastEventId(r)
+	if err != nil {
+		ErrorResponse("Invalid Last-Event-ID.", w, http.StatusBadRequest)
+		return
+	}
+
+	subscription, missed := notificationHub.Subscribe(topic, lastEventId)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+	for _, event := range missed {
+		writeStreamEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-subscription.Events:
+			if !open {
+				return
+			}
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			// Comment lines keep proxies from closing an idle connection
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// @Desc: [Stream] The id of the last event the client received, from the Last-Event-ID header sent on reconnection or the `last_event_id` query parameter, 0 if neither is given.
+func parseLastEventId(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if len(value) == 0 {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if len(value) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// @Desc: [Stream] Write a hub event in the text/event-stream format.
+func writeStreamEvent(w http.ResponseWriter, event hubEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, event.Data)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Server-Sent Event Streams
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [VALID] Reconnecting with a Last-Event-ID should replay only the notifications published after it.
+func TestStudentStreamReplaysMissedEvents(t *testing.T) {
+	student := "stream@gmail.com"
+	notificationHub.Publish(studentTopic(student), streamEventNotification, map[string]string{"notification": "seen"})
+	subscription, seen := notificationHub.Subscribe(studentTopic(student), 0)
+	subscription.Close()
+	notificationHub.Publish(studentTopic(student), streamEventNotification, map[string]string{"notification": "missed"})
+
+	// The client has already gone away, so the handler returns right after replaying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "/api/students/"+student+"/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(seen[len(seen)-1].Id, 10))
+	req = mux.SetURLVars(req, map[string]string{"student": student})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(StudentStream)
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"), "Response should be an event stream.")
+	assert.Contains(t, body, "event: notification\ndata: {\"notification\":\"missed\"}\n\n", "Missed event should be replayed.")
+	assert.NotContains(t, body, "seen", "Events before the Last-Event-ID should not be replayed.")
+	log.Println("SUCCESS: TestStudentStreamReplaysMissedEvents")
+}
+
+// @Desc: [FAIL] Streaming with a malformed Last-Event-ID, which should fail with HTTP code 400.
+func TestTeacherStreamInvalidLastEventId(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/teachers/t1@gmail.com/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "abc")
+	req = mux.SetURLVars(req, map[string]string{"teacher": "t1@gmail.com"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(TeacherStream)
+	handler.ServeHTTP(rr, req)
+
+	expected := `{"message":"Invalid Last-Event-ID."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestTeacherStreamInvalidLastEventId")
+}
@@ -0,0 +1,391 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/victortanzy123/govtech-assignment-swe/config"
+	"github.com/victortanzy123/govtech-assignment-swe/model"
+)
+
+// Columns selected whenever a NotificationTemplate row is read back through scanTemplate
+const templateColumns = "id, teacher, name, body, created_at, updated_at"
+
+const maxTemplateNameLength = 100
+
+// Data a notification template is rendered with for each recipient
+type templateData struct {
+	StudentName  string
+	StudentEmail string
+	TeacherEmail string
+	Date         string
+}
+
+// Placeholders a template may use, e.g. {{.StudentName}}
+var templateVariables = map[string]bool{
+	"StudentName":  true,
+	"StudentEmail": true,
+	"TeacherEmail": true,
+	"Date":         true,
+}
+
+/*///////////////////////////////////////////////////////////////
+                     Notification Template Endpoints
+//////////////////////////////////////////////////////////////*/
+
+// CreateTemplate: Save a notification template for a teacher
+// URL : /teachers/{teacher}/templates
+// Parameters: name, body
+// Method: POST
+// Output: JSON Encoded Object of the saved template, else error message.
+func CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.NotificationTemplateBody
+	teacher := mux.Vars(r)["teacher"]
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTemplate(requestBody); err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	if exists, err := templateNameExists(db, teacher, requestBody.Name, 0); err != nil || exists {
+		templateConflictResponse(err, w)
+		return
+	}
+
+	now := time.Now().UTC()
+	saved := model.NotificationTemplate{Teacher: teacher, Name: requestBody.Name, Body: requestBody.Body, CreatedAt: now, UpdatedAt: now}
+	result, err := db.Exec("INSERT INTO NotificationTemplate(teacher, name, body, created_at, updated_at) VALUES(?, ?, ?, ?, ?)",
+		saved.Teacher, saved.Name, saved.Body, saved.CreatedAt, saved.UpdatedAt)
+	if err != nil {
+		ErrorResponse("Failed to save template.", w, http.StatusNotFound)
+		return
+	}
+	saved.Id, err = result.LastInsertId()
+	if err != nil {
+		ErrorResponse("Failed to save template.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// ListTemplates: List all notification templates of a teacher
+// URL : /teachers/{teacher}/templates
+// Parameters: teacher
+// Method: GET
+// Output: JSON Encoded Array of templates, else error message.
+func ListTemplates(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+
+	db := config.Connect()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+templateColumns+" FROM NotificationTemplate WHERE teacher = ? ORDER BY name", teacher)
+	if err != nil {
+		ErrorResponse("Failed to get templates.", w, http.StatusNotFound)
+		return
+	}
+	defer rows.Close()
+
+	templates := make([]model.NotificationTemplate, 0)
+	for rows.Next() {
+		saved, err := scanTemplate(rows)
+		if err != nil {
+			ErrorResponse("Failed to get templates.", w, http.StatusNotFound)
+			return
+		}
+		templates = append(templates, saved)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// GetTemplate: Retrieve a single notification template of a teacher
+// URL : /teachers/{teacher}/templates/{id}
+// Parameters: teacher, id
+// Method: GET
+// Output: JSON Encoded Object of the template, else error message.
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid template id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	saved, err := getTeacherTemplate(db, teacher, id)
+	if err != nil {
+		templateNotFoundResponse(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// UpdateTemplate: Replace the name and body of a notification template
+// URL : /teachers/{teacher}/templates/{id}
+// Parameters: name, body
+// Method: PUT
+// Output: JSON Encoded Object of the updated template, else error message.
+func UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var requestBody model.NotificationTemplateBody
+	teacher := mux.Vars(r)["teacher"]
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid template id.", w, http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		ErrorResponse("Invalid request body format.", w, http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTemplate(requestBody); err != nil {
+		ErrorResponse(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	saved, err := getTeacherTemplate(db, teacher, id)
+	if err != nil {
+		templateNotFoundResponse(err, w)
+		return
+	}
+
+	if exists, err := templateNameExists(db, teacher, requestBody.Name, id); err != nil || exists {
+		templateConflictResponse(err, w)
+		return
+	}
+
+	saved.Name = requestBody.Name
+	saved.Body = requestBody.Body
+	saved.UpdatedAt = time.Now().UTC()
+	_, err = db.Exec("UPDATE NotificationTemplate SET name = ?, body = ?, updated_at = ? WHERE id = ?", saved.Name, saved.Body, saved.UpdatedAt, id)
+	if err != nil {
+		ErrorResponse("Failed to save template.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// DeleteTemplate: Delete a notification template of a teacher
+// URL : /teachers/{teacher}/templates/{id}
+// Parameters: teacher, id
+// Method: DELETE
+// Output: No content if successful, else error message.
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	teacher := mux.Vars(r)["teacher"]
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		ErrorResponse("Invalid template id.", w, http.StatusBadRequest)
+		return
+	}
+
+	db := config.Connect()
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM NotificationTemplate WHERE id = ? AND teacher = ?", id, teacher)
+	if err != nil {
+		ErrorResponse("Failed to delete template.", w, http.StatusNotFound)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ErrorResponse("Template not found.", w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*///////////////////////////////////////////////////////////////
+                     Template Parsing & Rendering
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [NotificationTemplate] Parse a template body, rejecting any placeholder other than the supported variables.
+func parseTemplate(body string) (*template.Template, error) {
+	parsed, err := template.New("notification").Parse(body)
+	if err != nil {
+		return nil, errors.New("Invalid template syntax.")
+	}
+
+	// Templates defined inside the body are checked too, as {{template}} renders them with the same data. The body comes first, then the others by name
+	templates := parsed.Templates()
+	sort.Slice(templates, func(a, b int) bool {
+		if (templates[a] == parsed) != (templates[b] == parsed) {
+			return templates[a] == parsed
+		}
+		return templates[a].Name() < templates[b].Name()
+	})
+	var unknown []string
+	for _, defined := range templates {
+		walkTemplate(defined.Tree.Root, func(field string) {
+			if !templateVariables[field] {
+				unknown = append(unknown, field)
+			}
+		})
+	}
+	if len(unknown) > 0 {
+		return nil, errors.New("Unknown template variables: " + strings.Join(unknown, ", ") + ".")
+	}
+	return parsed, nil
+}
+
+// @Desc: [NotificationTemplate] Render a template body for one recipient, falling back to their display name when they have no profile name.
+func renderTemplate(body string, teacher string, student string, name string, sendAt time.Time) (string, error) {
+	parsed, err := parseTemplate(body)
+	if err != nil {
+		return "", err
+	}
+
+	if len(name) == 0 {
+		name = studentDisplayName(student)
+	}
+	var rendered strings.Builder
+	err = parsed.Execute(&rendered, templateData{
+		StudentName:  name,
+		StudentEmail: student,
+		TeacherEmail: teacher,
+		Date:         sendAt.Format(config.TemplateDateLayout),
+	})
+	return rendered.String(), err
+}
+
+/*///////////////////////////////////////////////////////////////
+                        Helper Functions
+//////////////////////////////////////////////////////////////*/
+
+// @Desc: [parseTemplate] Calls visit with the name of every field referenced from the data, e.g. "StudentName" for {{.StudentName}} or {{$.StudentName}}.
+// Chained fields such as {{.StudentName.Foo}} are reported in full so that they are rejected as well.
+func walkTemplate(node parse.Node, visit func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplate(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplate(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTemplate(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplate(arg, visit)
+		}
+	case *parse.FieldNode:
+		visit(strings.Join(n.Ident, "."))
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 {
+			visit(strings.Join(n.Ident[1:], "."))
+		}
+	case *parse.ChainNode:
+		walkTemplate(n.Node, visit)
+		visit(strings.Join(n.Field, "."))
+	case *parse.IfNode:
+		walkTemplate(n.Pipe, visit)
+		walkTemplate(n.List, visit)
+		walkTemplate(n.ElseList, visit)
+	case *parse.RangeNode:
+		walkTemplate(n.Pipe, visit)
+		walkTemplate(n.List, visit)
+		walkTemplate(n.ElseList, visit)
+	case *parse.WithNode:
+		walkTemplate(n.Pipe, visit)
+		walkTemplate(n.List, visit)
+		walkTemplate(n.ElseList, visit)
+	case *parse.TemplateNode:
+		walkTemplate(n.Pipe, visit)
+	}
+}
+
+// @Desc: [NotificationTemplate] Check the name and body of a template before it is saved.
+func validateTemplate(requestBody model.NotificationTemplateBody) error {
+	if len(strings.TrimSpace(requestBody.Name)) == 0 || len(requestBody.Name) > maxTemplateNameLength {
+		return errors.New("Invalid template name.")
+	}
+	if len(strings.TrimSpace(requestBody.Body)) == 0 {
+		return errors.New("Empty template body.")
+	}
+	_, err := parseTemplate(requestBody.Body)
+	return err
+}
+
+// @Desc: [NotificationTemplate] Scans a NotificationTemplate row selected with templateColumns.
+func scanTemplate(row rowScanner) (model.NotificationTemplate, error) {
+	var saved model.NotificationTemplate
+	err := row.Scan(&saved.Id, &saved.Teacher, &saved.Name, &saved.Body, &saved.CreatedAt, &saved.UpdatedAt)
+	return saved, err
+}
+
+// @Desc: [NotificationTemplate] Retrieve a template by id, only if it belongs to the teacher.
+func getTeacherTemplate(db *sql.DB, teacher string, id int64) (model.NotificationTemplate, error) {
+	row := db.QueryRow("SELECT "+templateColumns+" FROM NotificationTemplate WHERE id = ? AND teacher = ?", id, teacher)
+	return scanTemplate(row)
+}
+
+// @Desc: [NotificationTemplate] Whether the teacher has another template (other than excludeId) with the same name.
+func templateNameExists(db *sql.DB, teacher string, name string, excludeId int64) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM NotificationTemplate WHERE teacher = ? AND name = ? AND id <> ?", teacher, name, excludeId).Scan(&count)
+	return count > 0, err
+}
+
+func templateNotFoundResponse(err error, w http.ResponseWriter) {
+	if err == sql.ErrNoRows {
+		ErrorResponse("Template not found.", w, http.StatusNotFound)
+		return
+	}
+	ErrorResponse("Failed to retrieve template.", w, http.StatusNotFound)
+}
+
+func templateConflictResponse(err error, w http.ResponseWriter) {
+	if err != nil {
+		ErrorResponse("Failed to save template.", w, http.StatusNotFound)
+		return
+	}
+	ErrorResponse("Template name has been used previously.", w, http.StatusConflict)
+}
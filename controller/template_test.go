@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+/*///////////////////////////////////////////////////////////////
+                	Notification Templates
+    //////////////////////////////////////////////////////////////*/
+
+// @Desc: [FAIL] Saving a template with an unknown placeholder, which should fail with HTTP code 400 before reaching the database.
+func TestCreateTemplateUnknownVariable(t *testing.T) {
+	var jsonBody = []byte(`{"name": "excursion", "body": "Dear {{.StudentName}}, meet at {{.Venue}} with {{$.Bag.Colour}}"}`)
+	req, err := http.NewRequest("POST", "/api/teachers/t1@gmail.com/templates", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"teacher": "t1@gmail.com"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CreateTemplate)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Unknown template variables: Venue, Bag.Colour."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestCreateTemplateUnknownVariable")
+}
+
+// @Desc: [FAIL] Placeholders inside templates defined in the body should be checked as well, since {{template}} renders them with the same data.
+func TestParseTemplateDefinedUnknownVariable(t *testing.T) {
+	_, err := parseTemplate(`{{define "greeting"}}Dear {{.Guardian}}{{end}}{{template "greeting" .}}, meet at {{.Venue}}`)
+	assert.EqualError(t, err, "Unknown template variables: Venue, Guardian.")
+
+	_, err = parseTemplate(`{{define "greeting"}}Dear {{.StudentName}}{{end}}{{template "greeting" .}}`)
+	assert.NoError(t, err)
+	log.Println("SUCCESS: TestParseTemplateDefinedUnknownVariable")
+}
+
+// @Desc: [FAIL] Saving a template with broken syntax, which should fail with HTTP code 400.
+func TestCreateTemplateInvalidSyntax(t *testing.T) {
+	var jsonBody = []byte(`{"name": "excursion", "body": "Dear {{.StudentName"}`)
+	req, err := http.NewRequest("POST", "/api/teachers/t1@gmail.com/templates", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"teacher": "t1@gmail.com"})
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CreateTemplate)
+	handler.ServeHTTP(rr, req)
+	status := rr.Code
+
+	// Check the response body is what we expect.
+	expected := `{"message":"Invalid template syntax."}`
+	actual := strings.TrimRight(rr.Body.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status, "Status code should be 400")
+	assert.Equal(t, expected, actual, "Response should be the same as expected.")
+	log.Println("SUCCESS: TestCreateTemplateInvalidSyntax")
+}
+
+// @Desc: [VALID] Rendering a template should fill in every supported variable for the recipient.
+func TestRenderTemplate(t *testing.T) {
+	body := "Dear {{.StudentName}} ({{.StudentEmail}}), {{if .Date}}on {{.Date}}{{end}} from {{$.TeacherEmail}}"
+	rendered, err := renderTemplate(body, "t1@gmail.com", "s1@gmail.com", "", time.Date(2023, 2, 16, 8, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Dear s1 (s1@gmail.com), on 16 Feb 2023 from t1@gmail.com", rendered, "Template should be rendered for the recipient.")
+	log.Println("SUCCESS: TestRenderTemplate")
+}
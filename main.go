@@ -1,6 +1,7 @@
 package main
 
 import (
+    "flag"
     "fmt"
 	"log"
 	"net/http"
@@ -8,18 +9,115 @@ import (
     _ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 	
+	"github.com/victortanzy123/govtech-assignment-swe/config"
 	"github.com/victortanzy123/govtech-assignment-swe/controller"
 
 )
 
 
 func main() {
+	// Print the token a classroom display uses to connect to a teacher's live gateway, e.g. -gateway-token t1@gmail.com
+	gatewayTeacher := flag.String("gateway-token", "", "print the live gateway token of a teacher and exit")
+	// Send SMS through a fake gateway served at /dev/sms, which logs them instead, e.g. when developing without an SMS provider
+	fakeSMS := flag.Bool("fake-sms", false, "send SMS through a local fake gateway that logs them")
+	flag.Parse()
+	if len(*gatewayTeacher) > 0 {
+		fmt.Println(controller.GatewayToken(*gatewayTeacher))
+		return
+	}
+
 	router := mux.NewRouter()
 	
 	router.HandleFunc("/api/commonstudents", controller.CommonStudents).Methods("GET")
 	router.HandleFunc("/api/register", controller.RegisterStudents).Methods("POST")
 	router.HandleFunc("/api/suspend", controller.SuspendStudent).Methods("POST")
 	router.HandleFunc("/api/retrievefornotifications", controller.RetrieveForNotification).Methods("POST")
+	router.HandleFunc("/api/teachers", controller.CreateTeacher).Methods("POST")
+	router.HandleFunc("/api/teachers", controller.ListTeachers).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}", controller.GetTeacher).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}", controller.UpdateTeacher).Methods("PUT")
+	router.HandleFunc("/api/teachers/{teacher}", controller.DeleteTeacher).Methods("DELETE")
+	router.HandleFunc("/api/students", controller.CreateStudent).Methods("POST")
+	router.HandleFunc("/api/students", controller.ListStudents).Methods("GET")
+	router.HandleFunc("/api/students/{student}", controller.GetStudent).Methods("GET")
+	router.HandleFunc("/api/students/{student}", controller.UpdateStudent).Methods("PUT")
+	router.HandleFunc("/api/students/{student}", controller.DeleteStudent).Methods("DELETE")
+	router.HandleFunc("/api/teachers/{teacher}/templates", controller.CreateTemplate).Methods("POST")
+	router.HandleFunc("/api/teachers/{teacher}/templates", controller.ListTemplates).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}/templates/{id}", controller.GetTemplate).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}/templates/{id}", controller.UpdateTemplate).Methods("PUT")
+	router.HandleFunc("/api/teachers/{teacher}/templates/{id}", controller.DeleteTemplate).Methods("DELETE")
+	router.HandleFunc("/api/students/{student}/preferences", controller.StudentPreferences).Methods("GET")
+	router.HandleFunc("/api/students/{student}/preferences", controller.UpdateStudentPreferences).Methods("PUT")
+	router.HandleFunc("/api/students/{student}/contact", controller.StudentContact).Methods("GET")
+	router.HandleFunc("/api/students/{student}/contact", controller.UpdateStudentContact).Methods("PUT")
+	router.HandleFunc("/api/students/{student}/guardians", controller.StudentGuardians).Methods("GET")
+	router.HandleFunc("/api/students/{student}/guardians/{id}", controller.LinkStudentGuardian).Methods("PUT")
+	router.HandleFunc("/api/students/{student}/guardians/{id}", controller.UnlinkStudentGuardian).Methods("DELETE")
+	router.HandleFunc("/api/guardians", controller.CreateGuardian).Methods("POST")
+	router.HandleFunc("/api/guardians/{id}", controller.GetGuardian).Methods("GET")
+	router.HandleFunc("/api/guardians/{id}", controller.UpdateGuardian).Methods("PUT")
+	router.HandleFunc("/api/guardians/{id}", controller.DeleteGuardian).Methods("DELETE")
+	router.HandleFunc("/api/students/{student}/inbox", controller.StudentInbox).Methods("GET")
+	router.HandleFunc("/api/students/{student}/inbox/{id}/read", controller.MarkInboxNotificationRead).Methods("POST")
+	router.HandleFunc("/api/students/{student}/inbox/{id}/archive", controller.ArchiveInboxNotification).Methods("POST")
+	router.HandleFunc("/api/students/{student}/inbox/{id}/acknowledge", controller.AcknowledgeNotification).Methods("POST")
+	router.HandleFunc("/api/students/{student}/digests", controller.StudentDigests).Methods("GET")
+	router.HandleFunc("/api/students/{student}/stream", controller.StudentStream).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}/stream", controller.TeacherStream).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}/live", controller.ClassroomGateway).Methods("GET")
+	router.HandleFunc("/api/teachers/{teacher}/moderation", controller.ModerationHistory).Methods("GET")
+	router.HandleFunc("/api/admin/ratelimits/{teacher}", controller.TeacherRateLimit).Methods("GET")
+	router.HandleFunc("/api/admin/ratelimits/{teacher}", controller.UpdateTeacherRateLimit).Methods("PUT")
+	router.HandleFunc("/api/admin/ratelimits/{teacher}", controller.DeleteTeacherRateLimit).Methods("DELETE")
+	router.HandleFunc("/api/attachments/{id}", controller.DownloadAttachment).Methods("GET")
+	router.HandleFunc("/api/unsubscribe", controller.UnsubscribePage).Methods("GET")
+	router.HandleFunc("/api/unsubscribe", controller.Unsubscribe).Methods("POST")
+	router.HandleFunc("/api/classes/register", controller.RegisterClassStudents).Methods("POST")
+	router.HandleFunc("/api/classes/{class}", controller.ClassStudents).Methods("GET")
+	router.HandleFunc("/api/notifications/scheduled", controller.ListScheduledNotifications).Methods("GET")
+	router.HandleFunc("/api/notifications/search", controller.SearchNotifications).Methods("GET")
+	router.HandleFunc("/api/notifications/{id}/cancel", controller.CancelScheduledNotification).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/reschedule", controller.RescheduleNotification).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/acknowledgements", controller.AcknowledgementReport).Methods("GET")
+	router.HandleFunc("/api/notifications/{id}/edit", controller.EditNotification).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/recall", controller.RecallNotification).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/history", controller.NotificationHistory).Methods("GET")
+	router.HandleFunc("/api/notifications/{id}/replies", controller.PostReply).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/replies", controller.ListReplies).Methods("GET")
+	router.HandleFunc("/api/notifications/{id}/replies/{reply}/moderate", controller.ModerateReply).Methods("POST")
+	router.HandleFunc("/api/recurringnotifications", controller.CreateRecurringNotification).Methods("POST")
+	router.HandleFunc("/api/recurringnotifications", controller.ListRecurringNotifications).Methods("GET")
+	router.HandleFunc("/api/recurringnotifications/{id}/pause", controller.PauseRecurringNotification).Methods("POST")
+	router.HandleFunc("/api/recurringnotifications/{id}/resume", controller.ResumeRecurringNotification).Methods("POST")
+	router.HandleFunc("/api/recurringnotifications/{id}/preview", controller.PreviewRecurringNotification).Methods("GET")
+
+	// Push notifications by email once an SMTP server has been configured
+	if len(config.SMTPAddress) > 0 {
+		controller.RegisterChannel(controller.NewEmailChannel())
+	}
+
+	// Text notifications to students with a phone number once an SMS gateway has been configured, or through the fake one
+	if *fakeSMS {
+		router.Handle("/dev/sms", &controller.FakeSMSGateway{Token: config.SMSGatewayToken}).Methods("POST")
+		controller.RegisterChannel(controller.NewSMSChannel(config.PublicBaseURL + "/dev/sms"))
+	} else if len(config.SMSGatewayURL) > 0 {
+		controller.RegisterChannel(controller.NewSMSChannel(config.SMSGatewayURL))
+	}
+
+	// Search notifications in memory on databases without full-text search
+	if config.SearchBackend == "memory" {
+		db := config.Connect()
+		index, err := controller.NewMemorySearchIndex(db)
+		db.Close()
+		if err != nil {
+			log.Fatal("Failed to load the search index: ", err)
+		}
+		controller.UseSearchIndex(index)
+	}
+
+	// Dispatch scheduled & recurring notifications in the background
+	go controller.StartScheduler(config.SchedulerInterval)
 
 	fmt.Println("Connected to port 8080")
 	log.Fatal(http.ListenAndServe(":8080", router))
@@ -1,5 +1,8 @@
 package model
  
+import (
+    "time"
+)
 
 
 
@@ -22,6 +25,23 @@ type Notification struct {
 	Student string `form:"student" json:"student"`
 }
 
+type Profile struct {
+    Id int64 `json:"id"`
+    Email string `json:"email"`
+    Name string `json:"name"`
+    Status string `json:"status"`
+    Metadata map[string]string `json:"metadata"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ProfileBody struct {
+    Email string `json:"email"`
+    Name string `json:"name"`
+    Status string `json:"status"`
+    Metadata map[string]string `json:"metadata"`
+}
+
 type StudentRegistration struct {
     Teacher string `json:"teacher"`
     Students []string `json:"students"`
@@ -46,17 +66,376 @@ type Student struct {
 type RetrieveForNotificationBody struct {
     Teacher string `json:"teacher"`
     Notification string `json:"notification"`
+    SendAt *time.Time `json:"send_at,omitempty"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+    RenderMentions string `json:"render_mentions,omitempty"`
+    TemplateId *int64 `json:"template_id,omitempty"`
+    Priority string `json:"priority,omitempty"`
+    IncludeSuspended bool `json:"include_suspended,omitempty"`
+    RequiresAck bool `json:"requires_ack,omitempty"`
+    RepliesVisible bool `json:"replies_visible,omitempty"`
+    Format string `json:"format,omitempty"`
+    Language string `json:"language,omitempty"`
+    Variants map[string]string `json:"variants,omitempty"`
+    Audience string `json:"audience,omitempty"`
+}
+
+type MentionSpan struct {
+    Offset int `json:"offset"`
+    Length int `json:"length"`
+    Email string `json:"email,omitempty"`
+    Group string `json:"group,omitempty"`
+}
+
+type RecipientGroup struct {
+    Mention string `json:"mention"`
+    Students []string `json:"students"`
+    Dropped []DroppedRecipient `json:"dropped,omitempty"`
+}
+
+type DroppedRecipient struct {
+    Student string `json:"student"`
+    Reason string `json:"reason"`
 }
 
 type RetrieveForNotificationResponse struct {
     Teacher string `json:"teacher"`
     Notification string `json:"notification"`
+    Mentions []MentionSpan `json:"mentions"`
+    RenderedNotification string `json:"rendered_notification,omitempty"`
     Students []string `json:"students"`
+    RecipientBreakdown []RecipientGroup `json:"recipient_breakdown,omitempty"`
+    Guardians []GuardianRecipient `json:"guardians,omitempty"`
+    RenderedMessages map[string]string `json:"rendered_messages,omitempty"`
+    InvalidMentions []string `json:"invalid_mentions,omitempty"`
+    Attachments []Attachment `json:"attachments,omitempty"`
+    Moderation *ModerationResult `json:"moderation,omitempty"`
+    HTML string `json:"html,omitempty"`
+    Text string `json:"text,omitempty"`
+}
+
+type Attachment struct {
+    Id int64 `json:"id"`
+    Filename string `json:"filename"`
+    ContentType string `json:"content_type"`
+    Size int64 `json:"size"`
+    SHA256 string `json:"sha256"`
+    URL string `json:"url,omitempty"`
 }
 
+type ScheduledNotification struct {
+    Id int64 `json:"id"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Mentions []MentionSpan `json:"mentions"`
+    Status string `json:"status"`
+    SendAt time.Time `json:"send_at"`
+    CreatedAt time.Time `json:"created_at"`
+    SentAt *time.Time `json:"sent_at,omitempty"`
+    EditedAt *time.Time `json:"edited_at,omitempty"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+    RecurringId *int64 `json:"recurring_id,omitempty"`
+    TemplateId *int64 `json:"template_id,omitempty"`
+    Priority string `json:"priority"`
+    IncludeSuspended bool `json:"include_suspended,omitempty"`
+    RequiresAck bool `json:"requires_ack,omitempty"`
+    RepliesVisible bool `json:"replies_visible,omitempty"`
+    Format string `json:"format"`
+    Language string `json:"language"`
+    Audience string `json:"audience"`
+    Variants map[string]string `json:"variants,omitempty"`
+    Attachments []Attachment `json:"attachments,omitempty"`
+    Moderation *ModerationResult `json:"moderation,omitempty"`
+}
+
+type CancelNotificationBody struct {
+    Teacher string `json:"teacher"`
+}
+
+type EditNotificationBody struct {
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+}
+
+type NotificationVersion struct {
+    Version int `json:"version"`
+    Notification string `json:"notification"`
+    ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type Reply struct {
+    Id int64 `json:"id"`
+    NotificationId int64 `json:"notification_id"`
+    Student string `json:"student"`
+    Reply string `json:"reply"`
+    CreatedAt time.Time `json:"created_at"`
+    HiddenAt *time.Time `json:"hidden_at,omitempty"`
+}
+
+type ReplyBody struct {
+    Student string `json:"student"`
+    Reply string `json:"reply"`
+}
+
+type ModerateReplyBody struct {
+    Teacher string `json:"teacher"`
+    Hidden bool `json:"hidden"`
+}
+
+type RescheduleNotificationBody struct {
+    Teacher string `json:"teacher"`
+    SendAt time.Time `json:"send_at"`
+}
+
+type RecurringNotification struct {
+    Id int64 `json:"id"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Mentions []MentionSpan `json:"mentions"`
+    Schedule string `json:"schedule"`
+    Timezone string `json:"timezone"`
+    Status string `json:"status"`
+    NextRunAt time.Time `json:"next_run_at"`
+    LastRunAt *time.Time `json:"last_run_at,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+    Moderation *ModerationResult `json:"moderation,omitempty"`
+}
+
+type CreateRecurringNotificationBody struct {
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Schedule string `json:"schedule"`
+    Timezone string `json:"timezone"`
+}
+
+type RecurringNotificationActionBody struct {
+    Teacher string `json:"teacher"`
+}
+
+type RecurringNotificationPreview struct {
+    Id int64 `json:"id"`
+    Schedule string `json:"schedule"`
+    Timezone string `json:"timezone"`
+    NextRuns []time.Time `json:"next_runs"`
+}
+
+type ClassRegistration struct {
+    Class string `json:"class"`
+    Students []string `json:"students"`
+}
+
+type NotificationTemplate struct {
+    Id int64 `json:"id"`
+    Teacher string `json:"teacher"`
+    Name string `json:"name"`
+    Body string `json:"body"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NotificationTemplateBody struct {
+    Name string `json:"name"`
+    Body string `json:"body"`
+}
+
+type QuietHours struct {
+    Start string `json:"start"`
+    End string `json:"end"`
+    Timezone string `json:"timezone"`
+}
+
+type StudentPreferences struct {
+    Student string `json:"student"`
+    OptedOut bool `json:"opted_out"`
+    MutedTeachers []string `json:"muted_teachers"`
+    MutedChannels []string `json:"muted_channels"`
+    QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+    Digest string `json:"digest"`
+    Language string `json:"language,omitempty"`
+}
+
+type RateLimits struct {
+    NotificationsPerHour int `json:"notifications_per_hour"`
+    NotificationsPerDay int `json:"notifications_per_day"`
+    RecipientsPerDay int `json:"recipients_per_day"`
+}
+
+type RateLimitOverride struct {
+    NotificationsPerHour *int `json:"notifications_per_hour,omitempty"`
+    NotificationsPerDay *int `json:"notifications_per_day,omitempty"`
+    RecipientsPerDay *int `json:"recipients_per_day,omitempty"`
+}
+
+type RateLimitStatus struct {
+    Teacher string `json:"teacher"`
+    Limits RateLimits `json:"limits"`
+    Override *RateLimitOverride `json:"override,omitempty"`
+    Remaining RateLimits `json:"remaining"`
+}
+
+type StudentContact struct {
+    Student string `json:"student"`
+    Phone string `json:"phone"`
+}
+
+type Guardian struct {
+    Id int64 `json:"id"`
+    Name string `json:"name"`
+    Email string `json:"email,omitempty"`
+    Phone string `json:"phone,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+    Students []GuardianLink `json:"students,omitempty"`
+}
+
+type GuardianBody struct {
+    Name string `json:"name"`
+    Email string `json:"email"`
+    Phone string `json:"phone"`
+}
+
+type GuardianLink struct {
+    Student string `json:"student"`
+    Relationship string `json:"relationship"`
+}
+
+type GuardianLinkBody struct {
+    Relationship string `json:"relationship"`
+}
+
+type StudentGuardian struct {
+    Guardian
+    Relationship string `json:"relationship"`
+}
+
+type GuardianRecipient struct {
+    Id int64 `json:"id"`
+    Name string `json:"name"`
+    Students []string `json:"students"`
+}
+
+type SMSMessage struct {
+    To string `json:"to"`
+    From string `json:"from"`
+    Text string `json:"text"`
+    Reference string `json:"reference"`
+    Part int `json:"part"`
+    Parts int `json:"parts"`
+}
+
+type Digest struct {
+    Id int64 `json:"id"`
+    Student string `json:"student"`
+    Frequency string `json:"frequency"`
+    PeriodEnd time.Time `json:"period_end"`
+    Notifications int `json:"notifications"`
+    Text string `json:"text"`
+    HTML string `json:"html"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type InboxNotification struct {
+    Id int64 `json:"id"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    HTML string `json:"html,omitempty"`
+    Mentions []MentionSpan `json:"mentions"`
+    Priority string `json:"priority"`
+    RequiresAck bool `json:"requires_ack,omitempty"`
+    AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+    Attachments []Attachment `json:"attachments,omitempty"`
+    SentAt *time.Time `json:"sent_at"`
+    ReadAt *time.Time `json:"read_at"`
+    ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+type Inbox struct {
+    Student string `json:"student"`
+    Total int `json:"total"`
+    Unread int `json:"unread"`
+    Limit int `json:"limit"`
+    Offset int `json:"offset"`
+    Notifications []InboxNotification `json:"notifications"`
+}
+
+type DeliveryUpdate struct {
+    NotificationId int64 `json:"notification_id"`
+    Status string `json:"status"`
+    SendAt time.Time `json:"send_at"`
+    SentAt *time.Time `json:"sent_at,omitempty"`
+    Students []string `json:"students,omitempty"`
+}
+
+type AcknowledgementStatus struct {
+    NotificationId int64 `json:"notification_id"`
+    Student string `json:"student"`
+    AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+    RemindersSent int `json:"reminders_sent"`
+    LastRemindedAt *time.Time `json:"last_reminded_at,omitempty"`
+}
+
+type AcknowledgementReport struct {
+    NotificationId int64 `json:"notification_id"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Acknowledged []AcknowledgementStatus `json:"acknowledged"`
+    Pending []AcknowledgementStatus `json:"pending"`
+}
 
 type MessageResponse struct {
     Message string `json:"message"`
 }
 
+type ModerationFinding struct {
+    Filter string `json:"filter"`
+    Rule string `json:"rule"`
+    Action string `json:"action"`
+    Match string `json:"match"`
+}
+
+type ModerationResult struct {
+    Outcome string `json:"outcome"`
+    Findings []ModerationFinding `json:"findings"`
+}
+
+type ModerationBlockedResponse struct {
+    Message string `json:"message"`
+    Moderation ModerationResult `json:"moderation"`
+}
+
+type ModerationRecord struct {
+    Id int64 `json:"id"`
+    NotificationId *int64 `json:"notification_id,omitempty"`
+    RecurringId *int64 `json:"recurring_id,omitempty"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Outcome string `json:"outcome"`
+    Findings []ModerationFinding `json:"findings"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
 
+
+type NotificationSearchQuery struct {
+    Query string `json:"query,omitempty"`
+    Teacher string `json:"teacher,omitempty"`
+    Student string `json:"student,omitempty"`
+    From *time.Time `json:"from,omitempty"`
+    To *time.Time `json:"to,omitempty"`
+    Limit int `json:"limit"`
+    Offset int `json:"offset"`
+}
+
+type NotificationSearchResult struct {
+    Id int64 `json:"id"`
+    Teacher string `json:"teacher"`
+    Notification string `json:"notification"`
+    Mentions []MentionSpan `json:"mentions"`
+    SentAt time.Time `json:"sent_at"`
+    Score float64 `json:"score,omitempty"`
+}
+
+type NotificationSearch struct {
+    NotificationSearchQuery
+    Total int `json:"total"`
+    Results []NotificationSearchResult `json:"results"`
+}
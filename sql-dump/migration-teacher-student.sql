@@ -0,0 +1,79 @@
+-- Migration from the email-only schema to Teacher & Student profiles.
+--
+-- Creates the `teacher` & `student` tables, backfills a profile for every email already stored, named after
+-- the part of the email before the '@', then adds the foreign keys from the relationship tables.
+-- Safe to run on a database created from the dump files without these tables.
+
+CREATE TABLE IF NOT EXISTS `teacher` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `email` varchar(45) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `status` varchar(10) NOT NULL DEFAULT 'active',
+  `metadata` json DEFAULT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `email_UNIQUE` (`email`),
+  KEY `status_idx` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `student` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `email` varchar(45) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `status` varchar(10) NOT NULL DEFAULT 'active',
+  `metadata` json DEFAULT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `email_UNIQUE` (`email`),
+  KEY `status_idx` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+--
+-- Backfill profiles from existing emails
+--
+
+INSERT INTO `teacher` (`email`, `name`, `status`, `created_at`, `updated_at`)
+SELECT `email`, SUBSTRING_INDEX(`email`, '@', 1), 'active', UTC_TIMESTAMP(), UTC_TIMESTAMP()
+FROM (
+  SELECT `teacher` AS `email` FROM `teach`
+  UNION SELECT `teacher` FROM `notification`
+  UNION SELECT `teacher` FROM `notificationmessage`
+) AS `emails`
+WHERE `email` NOT IN (SELECT `email` FROM `teacher`);
+
+INSERT INTO `student` (`email`, `name`, `status`, `created_at`, `updated_at`)
+SELECT `email`, SUBSTRING_INDEX(`email`, '@', 1), 'active', UTC_TIMESTAMP(), UTC_TIMESTAMP()
+FROM (
+  SELECT `student` AS `email` FROM `teach`
+  UNION SELECT `student` FROM `notification`
+  UNION SELECT `student` FROM `suspend`
+  UNION SELECT `student` FROM `classmember`
+  UNION SELECT `student` FROM `studentpreference`
+  UNION SELECT `student` FROM `studentcontact`
+  UNION SELECT `student` FROM `studentguardian`
+) AS `emails`
+WHERE `email` NOT IN (SELECT `email` FROM `student`);
+
+--
+-- Foreign keys from the relationship tables, by email so that existing rows & queries are unchanged.
+-- Other tables naming a teacher or student, e.g. `studentpreference` or `notificationrecipient`, keep no foreign key:
+-- deleting a profile through the API is refused while it is referenced by them, or deletes the profile's own settings with it
+--
+
+ALTER TABLE `teach`
+  ADD CONSTRAINT `teach_teacher_fk` FOREIGN KEY (`teacher`) REFERENCES `teacher` (`email`) ON UPDATE CASCADE,
+  ADD CONSTRAINT `teach_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE;
+
+ALTER TABLE `notification`
+  ADD KEY `student_idx` (`student`),
+  ADD CONSTRAINT `notification_teacher_fk` FOREIGN KEY (`teacher`) REFERENCES `teacher` (`email`) ON UPDATE CASCADE,
+  ADD CONSTRAINT `notification_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE;
+
+ALTER TABLE `suspend`
+  ADD CONSTRAINT `suspend_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE;
+
+ALTER TABLE `classmember`
+  ADD KEY `student_idx` (`student`),
+  ADD CONSTRAINT `classmember_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE;
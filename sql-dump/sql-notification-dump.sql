@@ -25,7 +25,10 @@ DROP TABLE IF EXISTS `notification`;
 CREATE TABLE `notification` (
   `teacher` varchar(45) NOT NULL,
   `student` varchar(45) NOT NULL,
-  PRIMARY KEY (`teacher`,`student`)
+  PRIMARY KEY (`teacher`,`student`),
+  KEY `student_idx` (`student`),
+  CONSTRAINT `notification_teacher_fk` FOREIGN KEY (`teacher`) REFERENCES `teacher` (`email`) ON UPDATE CASCADE,
+  CONSTRAINT `notification_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -0,0 +1,71 @@
+-- MySQL dump 10.13  Distrib 8.0.32, for Win64 (x86_64)
+--
+-- Host: localhost    Database: sys
+-- ------------------------------------------------------
+-- Server version	8.0.32
+
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!50503 SET NAMES utf8 */;
+/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+
+--
+-- Table structure for table `notificationmessage`
+--
+
+DROP TABLE IF EXISTS `notificationmessage`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `notificationmessage` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `teacher` varchar(45) NOT NULL,
+  `message` text NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `send_at` datetime NOT NULL,
+  `created_at` datetime NOT NULL,
+  `sent_at` datetime DEFAULT NULL,
+  `recurring_id` bigint DEFAULT NULL,
+  `template_id` bigint DEFAULT NULL,
+  `priority` varchar(10) NOT NULL DEFAULT 'normal',
+  `include_suspended` tinyint(1) NOT NULL DEFAULT '0',
+  `requires_ack` tinyint(1) NOT NULL DEFAULT '0',
+  `edited_at` datetime DEFAULT NULL,
+  `replies_visible` tinyint(1) NOT NULL DEFAULT '0',
+  `format` varchar(10) NOT NULL DEFAULT 'plain',
+  `language` varchar(35) NOT NULL DEFAULT 'en',
+  `expires_at` datetime DEFAULT NULL,
+  `audience` varchar(10) NOT NULL DEFAULT 'students',
+  `delivery_attempts` int NOT NULL DEFAULT '0',
+  PRIMARY KEY (`id`),
+  KEY `status_send_at` (`status`,`send_at`),
+  KEY `teacher` (`teacher`),
+  KEY `expires_at` (`expires_at`),
+  FULLTEXT KEY `message` (`message`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `notificationmessage`
+--
+
+LOCK TABLES `notificationmessage` WRITE;
+/*!40000 ALTER TABLE `notificationmessage` DISABLE KEYS */;
+/*!40000 ALTER TABLE `notificationmessage` ENABLE KEYS */;
+UNLOCK TABLES;
+/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
+
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+
+-- Dump completed on 2026-10-19 10:00:00
@@ -24,7 +24,8 @@ DROP TABLE IF EXISTS `suspend`;
 /*!50503 SET character_set_client = utf8mb4 */;
 CREATE TABLE `suspend` (
   `student` varchar(45) NOT NULL,
-  PRIMARY KEY (`student`)
+  PRIMARY KEY (`student`),
+  CONSTRAINT `suspend_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
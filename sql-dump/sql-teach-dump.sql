@@ -27,7 +27,9 @@ CREATE TABLE `teach` (
   `student` varchar(45) NOT NULL,
   PRIMARY KEY (`teacher`,`student`),
   KEY `teacher_idx` (`teacher`),
-  KEY `student_idx` (`student`)
+  KEY `student_idx` (`student`),
+  CONSTRAINT `teach_teacher_fk` FOREIGN KEY (`teacher`) REFERENCES `teacher` (`email`) ON UPDATE CASCADE,
+  CONSTRAINT `teach_student_fk` FOREIGN KEY (`student`) REFERENCES `student` (`email`) ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='Relationship between teacher and student registration - Each student can be registered under multiple teachers.';
 /*!40101 SET character_set_client = @saved_cs_client */;
 